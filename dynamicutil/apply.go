@@ -0,0 +1,151 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// ApplyOptions contains the options of an Apply call
+type ApplyOptions struct {
+	// FieldManager is the name of the actor applying the object, it's required
+	// by server-side apply.
+	FieldManager string
+	// Force makes the FieldManager take ownership of the conflicting fields
+	// which are owned by other managers.
+	Force bool
+	// Scheme is used to set the apiVersion and kind of typed objects, see
+	// WithScheme.
+	Scheme *runtime.Scheme
+	// DryRun previews the apply without persisting it, see DryRunMode. The
+	// client-side mode compares the applied fields with the existing object,
+	// it doesn't detect the removal of fields previously applied by the
+	// FieldManager.
+	DryRun DryRunMode
+}
+
+// Apply applies the given object to the Kubernetes cluster with server-side
+// apply, the object is created if it doesn't exist yet.
+//
+// The object must have its apiVersion and kind set, unless it is a typed
+// object registered in opts.Scheme. On success the object returned by the
+// API server is decoded back into obj, with opts.DryRun set it's the object
+// which would be persisted.
+//
+// It returns the executed operation and an error.
+func Apply(ctx context.Context, c dynamic.NamespaceableResourceInterface, obj Object, opts ApplyOptions) (OperationResult, error) {
+	if opts.FieldManager == "" {
+		return OperationResultNone, fmt.Errorf("FieldManager is required for server-side apply")
+	}
+
+	key := namespacedNameFromObject(obj)
+	cli := c.Namespace(key.Namespace)
+
+	objUns, err := stampedUnstructuredFromObject(opts.Scheme, obj.DeepCopyObject())
+	if err != nil {
+		return OperationResultNone, err
+	}
+	if objUns.GetAPIVersion() == "" || objUns.GetKind() == "" {
+		return OperationResultNone, fmt.Errorf("apiVersion and kind are required for server-side apply of %s", key)
+	}
+	// managedFields must be left empty in an apply request
+	objUns.SetManagedFields(nil)
+	data, err := objUns.MarshalJSON()
+	if err != nil {
+		return OperationResultNone, err
+	}
+
+	exists := true
+	fetchedUns, err := cli.Get(ctx, key.Name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return OperationResultNone, err
+		}
+		exists = false
+	}
+	existingUns := fetchedUns
+	if opts.DryRun == DryRunClient {
+		return applyClientDryRun(existingUns, exists, objUns), nil
+	}
+
+	force := opts.Force
+	fetchedUns, err = cli.Patch(ctx, key.Name, types.ApplyPatchType, data, metav1.PatchOptions{
+		DryRun:       dryRunOption(opts.DryRun),
+		FieldManager: opts.FieldManager,
+		Force:        &force,
+	})
+	if err != nil {
+		return OperationResultNone, err
+	}
+	if err = objectFromUnstructured(fetchedUns, obj); err != nil {
+		return OperationResultNone, err
+	}
+
+	switch {
+	case !exists:
+		return OperationResultCreated, nil
+	case opts.DryRun == DryRunServer && dryRunChanged(existingUns, fetchedUns):
+		return OperationResultUpdated, nil
+	case opts.DryRun != DryRunServer && fetchedUns.GetResourceVersion() != existingUns.GetResourceVersion():
+		return OperationResultUpdated, nil
+	default:
+		return OperationResultNone, nil
+	}
+}
+
+// applyClientDryRun returns the would-be result of applying objUns: the
+// existing object is updated unless it already holds all the applied values.
+func applyClientDryRun(existingUns *unstructured.Unstructured, exists bool, objUns *unstructured.Unstructured) OperationResult {
+	switch {
+	case !exists:
+		return OperationResultCreated
+	case containsApplied(existingUns.Object, objUns.Object):
+		return OperationResultNone
+	default:
+		return OperationResultUpdated
+	}
+}
+
+// containsApplied tells whether live holds the values of applied. Null
+// applied values, like the creationTimestamp of typed objects, are skipped,
+// and list elements are matched by index.
+func containsApplied(live, applied interface{}) bool {
+	switch applied := applied.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		liveMap, ok := live.(map[string]interface{})
+		if !ok {
+			return len(applied) == 0 && live == nil
+		}
+		for k, v := range applied {
+			if !containsApplied(liveMap[k], v) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		liveList, ok := live.([]interface{})
+		if !ok {
+			return len(applied) == 0 && live == nil
+		}
+		if len(liveList) != len(applied) {
+			return false
+		}
+		for i := range applied {
+			if !containsApplied(liveList[i], applied[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(normalizeNumber(live), normalizeNumber(applied))
+	}
+}
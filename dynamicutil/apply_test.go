@@ -0,0 +1,121 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+var _ = Describe("Apply", func() {
+	var deploymentCli dynamic.NamespaceableResourceInterface
+	var deploy *appsv1.Deployment
+	var applyOpts ApplyOptions
+
+	BeforeEach(func() {
+		deploymentCli = dynClient.Resource(deploymentGVR)
+
+		deploy = &appsv1.Deployment{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: deploymentGVK.GroupVersion().String(),
+				Kind:       deploymentGVK.Kind,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("deploy-%d", rand.Int31()),
+				Namespace: "default",
+			},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"foo": "bar"},
+				},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{"foo": "bar"},
+					},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Name:  "busybox",
+								Image: "busybox",
+							},
+						},
+					},
+				},
+			},
+		}
+
+		applyOpts = ApplyOptions{FieldManager: "dynamicutil-test"}
+	})
+
+	It("creates a new object if one doesn't exists", func() {
+		op, err := Apply(context.TODO(), deploymentCli, deploy, applyOpts)
+
+		By("returning no error")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("returning OperationResultCreated")
+		Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+		By("decoding the applied object")
+		Expect(deploy.ResourceVersion).NotTo(BeEmpty())
+		Expect(deploy.ManagedFields).NotTo(BeEmpty())
+	})
+
+	It("updates existing object", func() {
+		var scale int32 = 2
+		op, err := Apply(context.TODO(), deploymentCli, deploy, applyOpts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+		deploy.Spec.Replicas = &scale
+		op, err = Apply(context.TODO(), deploymentCli, deploy, applyOpts)
+
+		By("returning no error")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("returning OperationResultUpdated")
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+
+		By("actually having the deployment scaled")
+		fetchedUns, err := deploymentCli.Namespace(deploy.Namespace).Get(context.TODO(), deploy.Name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		replicas, _, err := unstructured.NestedInt64(fetchedUns.Object, "spec", "replicas")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicas).To(BeEquivalentTo(scale))
+	})
+
+	It("updates only changed objects", func() {
+		op, err := Apply(context.TODO(), deploymentCli, deploy, applyOpts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+		op, err = Apply(context.TODO(), deploymentCli, deploy, applyOpts)
+
+		By("returning no error")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("returning OperationResultNone")
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+	})
+
+	It("errors when FieldManager is empty", func() {
+		op, err := Apply(context.TODO(), deploymentCli, deploy, ApplyOptions{})
+
+		Expect(err).To(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+	})
+
+	It("errors when kind is missing", func() {
+		deploy.TypeMeta = metav1.TypeMeta{}
+		op, err := Apply(context.TODO(), deploymentCli, deploy, applyOpts)
+
+		Expect(err).To(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+	})
+})
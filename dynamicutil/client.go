@@ -0,0 +1,198 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// Client wraps a dynamic.Interface and resolves the resource of an object
+// from its kind, so the CreateOrUpdate family can be called with the object
+// only.
+type Client struct {
+	dynamic dynamic.Interface
+	mapper  meta.RESTMapper
+	scheme  *runtime.Scheme
+}
+
+// resettableRESTMapper is a RESTMapper with a cache which can be invalidated
+type resettableRESTMapper interface {
+	meta.RESTMapper
+	Reset()
+}
+
+// NewClient creates a Client from a dynamic client and a RESTMapper. The
+// scheme is used to look up the kind of typed objects, it may be nil if
+// only unstructured objects or objects with TypeMeta set are used.
+func NewClient(dynamicClient dynamic.Interface, mapper meta.RESTMapper, scheme *runtime.Scheme) *Client {
+	return &Client{
+		dynamic: dynamicClient,
+		mapper:  mapper,
+		scheme:  scheme,
+	}
+}
+
+// NewClientForConfig creates a Client for the given config, with a RESTMapper
+// backed by a cached discovery client.
+func NewClientForConfig(cfg *rest.Config, scheme *runtime.Scheme) (*Client, error) {
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+	return NewClient(dynamicClient, mapper, scheme), nil
+}
+
+// Resource returns the dynamic resource client of the given object. It fails
+// when the kind of the object is unknown, or when the object has no namespace
+// but its kind is namespaced, or the other way around.
+func (c *Client) Resource(obj Object) (dynamic.NamespaceableResourceInterface, error) {
+	gvk, err := gvkForObject(c.scheme, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping, err := c.restMapping(gvk)
+	if err != nil {
+		return nil, err
+	}
+
+	key := namespacedNameFromObject(obj)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if key.Namespace == "" {
+			return nil, fmt.Errorf("namespace is required for %s %s", gvk.Kind, key.Name)
+		}
+	} else if key.Namespace != "" {
+		return nil, fmt.Errorf("%s %s is cluster-scoped and cannot have namespace %s", gvk.Kind, key.Name, key.Namespace)
+	}
+	return c.dynamic.Resource(mapping.Resource), nil
+}
+
+// CreateOrUpdate is CreateOrUpdate with the resource of obj resolved by the Client
+func (c *Client) CreateOrUpdate(ctx context.Context, obj Object, f MutateFn, opts ...Option) (OperationResult, error) {
+	ri, err := c.Resource(obj)
+	if err != nil {
+		return OperationResultNone, err
+	}
+	return CreateOrUpdate(ctx, ri, obj, f, append([]Option{WithScheme(c.scheme)}, opts...)...)
+}
+
+// CreateOrPatch is CreateOrPatch with the resource of obj resolved by the Client
+func (c *Client) CreateOrPatch(ctx context.Context, obj Object, f MutateFn, opts ...Option) (OperationResult, error) {
+	ri, err := c.Resource(obj)
+	if err != nil {
+		return OperationResultNone, err
+	}
+	return CreateOrPatch(ctx, ri, obj, f, append([]Option{WithScheme(c.scheme)}, opts...)...)
+}
+
+// Apply is Apply with the resource of obj resolved by the Client
+func (c *Client) Apply(ctx context.Context, obj Object, opts ApplyOptions) (OperationResult, error) {
+	ri, err := c.Resource(obj)
+	if err != nil {
+		return OperationResultNone, err
+	}
+	if opts.Scheme == nil {
+		opts.Scheme = c.scheme
+	}
+	return Apply(ctx, ri, obj, opts)
+}
+
+// ClientSideApply is ClientSideApply with the resource of obj resolved by the Client
+func (c *Client) ClientSideApply(ctx context.Context, obj Object, opts ClientSideApplyOptions) (OperationResult, error) {
+	ri, err := c.Resource(obj)
+	if err != nil {
+		return OperationResultNone, err
+	}
+	if opts.Scheme == nil {
+		opts.Scheme = c.scheme
+	}
+	return ClientSideApply(ctx, ri, obj, opts)
+}
+
+// Delete is Delete with the resource of obj resolved by the Client
+func (c *Client) Delete(ctx context.Context, obj Object, opts DeleteOptions) (OperationResult, error) {
+	ri, err := c.Resource(obj)
+	if err != nil {
+		return OperationResultNone, err
+	}
+	return Delete(ctx, ri, obj, opts)
+}
+
+// AddFinalizer is AddFinalizer with the resource of obj resolved by the Client
+func (c *Client) AddFinalizer(ctx context.Context, obj Object, finalizer string) (OperationResult, error) {
+	ri, err := c.Resource(obj)
+	if err != nil {
+		return OperationResultNone, err
+	}
+	return AddFinalizer(ctx, ri, obj, finalizer)
+}
+
+// RemoveFinalizer is RemoveFinalizer with the resource of obj resolved by the Client
+func (c *Client) RemoveFinalizer(ctx context.Context, obj Object, finalizer string) (OperationResult, error) {
+	ri, err := c.Resource(obj)
+	if err != nil {
+		return OperationResultNone, err
+	}
+	return RemoveFinalizer(ctx, ri, obj, finalizer)
+}
+
+// restMapping returns the RESTMapping of gvk, the mapper cache is invalidated
+// once when the kind isn't found, as it may have been installed recently.
+func (c *Client) restMapping(gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		if mapper, ok := c.mapper.(resettableRESTMapper); ok {
+			mapper.Reset()
+			mapping, err = c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		}
+	}
+	return mapping, err
+}
+
+// gvkForObject returns the GroupVersionKind of obj. The kind of typed objects
+// is looked up in the scheme, TypeMeta is used when there's no scheme, or
+// to choose between several kinds the type is registered as.
+func gvkForObject(scheme *runtime.Scheme, obj runtime.Object) (schema.GroupVersionKind, error) {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	hasKind := gvk.Version != "" && gvk.Kind != ""
+	if _, isUnstructured := obj.(*unstructured.Unstructured); isUnstructured || scheme == nil {
+		if !hasKind {
+			return schema.GroupVersionKind{}, fmt.Errorf("cannot determine the kind of %T without apiVersion and kind", obj)
+		}
+		return gvk, nil
+	}
+
+	gvks, isUnversioned, err := scheme.ObjectKinds(obj)
+	if err != nil {
+		return schema.GroupVersionKind{}, fmt.Errorf("cannot determine the kind of %T: %w", obj, err)
+	}
+	if isUnversioned {
+		return schema.GroupVersionKind{}, fmt.Errorf("cannot determine the kind of unversioned type %T", obj)
+	}
+	if hasKind {
+		for _, registered := range gvks {
+			if registered == gvk {
+				return gvk, nil
+			}
+		}
+		return schema.GroupVersionKind{}, fmt.Errorf("%T is registered as %v, not as %s", obj, gvks, gvk)
+	}
+	if len(gvks) != 1 {
+		return schema.GroupVersionKind{}, fmt.Errorf("kind of %T is ambiguous, it is registered as %v", obj, gvks)
+	}
+	return gvks[0], nil
+}
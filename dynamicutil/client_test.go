@@ -0,0 +1,169 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("Client", func() {
+	var deploy *appsv1.Deployment
+	var deplSpec appsv1.DeploymentSpec
+
+	BeforeEach(func() {
+		deploy = &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("deploy-%d", rand.Int31()),
+				Namespace: "default",
+			},
+		}
+
+		deplSpec = appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"foo": "bar"},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"foo": "bar"},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "busybox",
+							Image: "busybox",
+						},
+					},
+				},
+			},
+		}
+	})
+
+	Context("with a discovery RESTMapper", func() {
+		var c *Client
+
+		BeforeEach(func() {
+			var err error
+			c, err = NewClientForConfig(cfg, clientgoscheme.Scheme)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("creates and updates a typed object without TypeMeta", func() {
+			op, err := c.CreateOrUpdate(context.TODO(), deploy, deploymentSpecr(deploy, deplSpec))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+			op, err = c.CreateOrUpdate(context.TODO(), deploy, deploymentScaler(deploy, 2))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+		})
+
+		It("creates a cluster-scoped object", func() {
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: fmt.Sprintf("ns-%d", rand.Int31()),
+				},
+			}
+			op, err := c.CreateOrPatch(context.TODO(), ns, deploymentIdentity)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(op).To(BeEquivalentTo(OperationResultCreated))
+		})
+	})
+
+	Context("with a static RESTMapper", func() {
+		var mapper *meta.DefaultRESTMapper
+		var c *Client
+
+		BeforeEach(func() {
+			mapper = meta.NewDefaultRESTMapper(nil)
+			mapper.Add(deploymentGVK, meta.RESTScopeNamespace)
+			mapper.Add(corev1.SchemeGroupVersion.WithKind("Namespace"), meta.RESTScopeRoot)
+			c = NewClient(dynClient, mapper, clientgoscheme.Scheme)
+		})
+
+		It("resolves the resource of a typed object from the scheme", func() {
+			op, err := c.CreateOrUpdate(context.TODO(), deploy, deploymentSpecr(deploy, deplSpec))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+			_, err = dynClient.Resource(deploymentGVR).Namespace(deploy.Namespace).Get(context.TODO(), deploy.Name, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("errors when a namespaced object has no namespace", func() {
+			deploy.Namespace = ""
+			_, err := c.Resource(deploy)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("errors when a cluster-scoped object has a namespace", func() {
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+			}
+			_, err := c.Resource(ns)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("errors when the kind is unknown to the RESTMapper", func() {
+			_, err := c.Resource(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+			})
+			Expect(meta.IsNoMatchError(err)).To(BeTrue())
+		})
+
+		It("resets the RESTMapper once when the kind is unknown", func() {
+			cmGVK := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+			resetting := &resettingRESTMapper{DefaultRESTMapper: mapper, onReset: func() {
+				mapper.Add(cmGVK, meta.RESTScopeNamespace)
+			}}
+			c = NewClient(dynClient, resetting, clientgoscheme.Scheme)
+
+			_, err := c.Resource(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resetting.resets).To(Equal(1))
+		})
+
+		It("errors when a typed object is not registered in the scheme", func() {
+			c = NewClient(dynClient, mapper, runtime.NewScheme())
+			_, err := c.Resource(deploy)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("errors when an unstructured object has no kind", func() {
+			_, err := c.Resource(&unstructured.Unstructured{})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("errors when a typed object has an ambiguous kind", func() {
+			scheme := runtime.NewScheme()
+			scheme.AddKnownTypes(appsv1.SchemeGroupVersion, &appsv1.Deployment{})
+			scheme.AddKnownTypes(schema.GroupVersion{Group: "apps", Version: "v1beta2"}, &appsv1.Deployment{})
+			c = NewClient(dynClient, mapper, scheme)
+
+			_, err := c.Resource(deploy)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+type resettingRESTMapper struct {
+	*meta.DefaultRESTMapper
+	onReset func()
+	resets  int
+}
+
+func (m *resettingRESTMapper) Reset() {
+	m.resets++
+	m.onReset()
+}
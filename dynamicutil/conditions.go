@@ -0,0 +1,202 @@
+package dynamicutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// GetConditions returns the status.conditions of obj. The fields of the
+// conditions which aren't part of metav1.Condition are left out.
+func GetConditions(obj *unstructured.Unstructured) ([]metav1.Condition, error) {
+	items, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return nil, err
+	}
+	conditions := make([]metav1.Condition, 0, len(items))
+	for i, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("status.conditions[%d] of %s is not an object", i, namespacedNameFromObject(obj))
+		}
+		var condition metav1.Condition
+		if err = unstructuredConverter.FromUnstructured(m, &condition); err != nil {
+			return nil, fmt.Errorf("invalid status.conditions[%d] of %s: %w", i, namespacedNameFromObject(obj), err)
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, nil
+}
+
+// GetCondition returns the condition of the given type of obj, or nil if
+// there's none.
+func GetCondition(obj *unstructured.Unstructured, conditionType string) (*metav1.Condition, error) {
+	conditions, err := GetConditions(obj)
+	if err != nil {
+		return nil, err
+	}
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// SetCondition adds the condition to the status.conditions of obj, or updates
+// the existing condition of the same type. The lastTransitionTime is only
+// changed when the status changes, it's set to now if the condition has
+// none. The fields of an existing condition which aren't part of
+// metav1.Condition are kept.
+//
+// It tells whether the conditions have changed.
+func SetCondition(obj *unstructured.Unstructured, condition metav1.Condition) (bool, error) {
+	items, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return false, err
+	}
+
+	index := -1
+	for i, item := range items {
+		if m, ok := item.(map[string]interface{}); ok && m["type"] == condition.Type {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		m, err := unstructuredConverter.ToUnstructured(&condition)
+		if err != nil {
+			return false, err
+		}
+		return true, unstructured.SetNestedSlice(obj.Object, append(items, m), "status", "conditions")
+	}
+
+	existing, ok := items[index].(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("status.conditions[%d] of %s is not an object", index, namespacedNameFromObject(obj))
+	}
+	if existing["status"] == string(condition.Status) {
+		// The existing lastTransitionTime is kept
+		condition.LastTransitionTime = metav1.Time{}
+	} else if condition.LastTransitionTime.IsZero() {
+		condition.LastTransitionTime = metav1.Now()
+	}
+	m, err := unstructuredConverter.ToUnstructured(&condition)
+	if err != nil {
+		return false, err
+	}
+
+	updated := make(map[string]interface{}, len(existing))
+	for k, v := range existing {
+		updated[k] = v
+	}
+	for k, v := range m {
+		if k == "lastTransitionTime" && condition.LastTransitionTime.IsZero() {
+			continue
+		}
+		if v == nil || v == "" || v == int64(0) {
+			// Optional fields of the condition which are unset
+			delete(updated, k)
+			continue
+		}
+		updated[k] = v
+	}
+	if equality.Semantic.DeepEqual(existing, updated) {
+		return false, nil
+	}
+	items[index] = updated
+	return true, unstructured.SetNestedSlice(obj.Object, items, "status", "conditions")
+}
+
+// RemoveCondition removes the condition of the given type from the
+// status.conditions of obj. It tells whether the conditions have changed.
+func RemoveCondition(obj *unstructured.Unstructured, conditionType string) (bool, error) {
+	items, found, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil || !found {
+		return false, err
+	}
+	kept := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok && m["type"] == conditionType {
+			continue
+		}
+		kept = append(kept, item)
+	}
+	if len(kept) == len(items) {
+		return false, nil
+	}
+	return true, unstructured.SetNestedSlice(obj.Object, kept, "status", "conditions")
+}
+
+// IsConditionStatus tells whether obj has the condition of the given type
+// with the given status. A missing condition has the unknown status.
+func IsConditionStatus(obj *unstructured.Unstructured, conditionType string, status metav1.ConditionStatus) (bool, error) {
+	condition, err := GetCondition(obj, conditionType)
+	if err != nil {
+		return false, err
+	}
+	if condition == nil {
+		return status == metav1.ConditionUnknown, nil
+	}
+	return condition.Status == status, nil
+}
+
+// IsConditionTrue tells whether obj has the condition of the given type with
+// the true status, and it's not stale: its observedGeneration, if set, is the
+// generation of obj.
+func IsConditionTrue(obj *unstructured.Unstructured, conditionType string) (bool, error) {
+	condition, err := GetCondition(obj, conditionType)
+	if err != nil || condition == nil {
+		return false, err
+	}
+	if condition.ObservedGeneration != 0 && condition.ObservedGeneration != obj.GetGeneration() {
+		return false, nil
+	}
+	return condition.Status == metav1.ConditionTrue, nil
+}
+
+// PatchConditions writes the status.conditions of obj through the status
+// subresource, as a JSON merge patch replacing the conditions. The
+// resourceVersion of obj, if any, is sent along so the patch fails with a
+// Conflict error if the object has changed since it was read.
+//
+// On success the patched object is decoded back into obj. It returns
+// OperationResultUpdatedStatusOnly, or OperationResultNone if the object
+// hasn't changed, and an error.
+func PatchConditions(ctx context.Context, c dynamic.NamespaceableResourceInterface, obj *unstructured.Unstructured) (OperationResult, error) {
+	conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return OperationResultNone, err
+	}
+	patch := map[string]interface{}{
+		"status": map[string]interface{}{"conditions": conditions},
+	}
+	resourceVersion := obj.GetResourceVersion()
+	if resourceVersion != "" {
+		patch["metadata"] = map[string]interface{}{"resourceVersion": resourceVersion}
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return OperationResultNone, err
+	}
+
+	key := namespacedNameFromObject(obj)
+	fetchedUns, err := c.Namespace(key.Namespace).Patch(ctx, key.Name, types.MergePatchType, data, metav1.PatchOptions{}, "status")
+	if err != nil {
+		return OperationResultNone, err
+	}
+	obj.Object = fetchedUns.Object
+	if resourceVersion != "" && fetchedUns.GetResourceVersion() == resourceVersion {
+		return OperationResultNone, nil
+	}
+	return OperationResultUpdatedStatusOnly, nil
+}
@@ -0,0 +1,157 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("Conditions", func() {
+	var obj *unstructured.Unstructured
+	var past metav1.Time
+
+	BeforeEach(func() {
+		past = metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+		obj = &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(deploymentGVK)
+		obj.SetName(fmt.Sprintf("deploy-%d", rand.Int31()))
+		obj.SetNamespace("default")
+		obj.SetGeneration(2)
+		Expect(unstructured.SetNestedSlice(obj.Object, []interface{}{
+			map[string]interface{}{
+				"type":               "Available",
+				"status":             "True",
+				"reason":             "MinimumReplicasAvailable",
+				"lastTransitionTime": "2020-01-01T00:00:00Z",
+				"lastUpdateTime":     "2020-01-01T00:00:00Z",
+			},
+		}, "status", "conditions")).To(Succeed())
+	})
+
+	It("gets conditions", func() {
+		condition, err := GetCondition(obj, "Available")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(condition.LastTransitionTime.Equal(&past)).To(BeTrue())
+		condition.LastTransitionTime = past
+		Expect(condition).To(Equal(&metav1.Condition{
+			Type:               "Available",
+			Status:             metav1.ConditionTrue,
+			Reason:             "MinimumReplicasAvailable",
+			LastTransitionTime: past,
+		}))
+
+		condition, err = GetCondition(obj, "Progressing")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(condition).To(BeNil())
+	})
+
+	It("adds conditions", func() {
+		changed, err := SetCondition(obj, metav1.Condition{Type: "Progressing", Status: metav1.ConditionTrue, Reason: "NewReplicaSetAvailable"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+
+		conditions, err := GetConditions(obj)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conditions).To(HaveLen(2))
+		Expect(conditions[1].Type).To(Equal("Progressing"))
+		Expect(conditions[1].LastTransitionTime.IsZero()).To(BeFalse())
+	})
+
+	It("keeps the lastTransitionTime when the status doesn't change", func() {
+		changed, err := SetCondition(obj, metav1.Condition{Type: "Available", Status: metav1.ConditionTrue, Reason: "Other", Message: "message"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+
+		condition, err := GetCondition(obj, "Available")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(condition.Reason).To(Equal("Other"))
+		Expect(condition.Message).To(Equal("message"))
+		Expect(condition.LastTransitionTime.Equal(&past)).To(BeTrue())
+
+		By("keeping the fields which aren't part of metav1.Condition")
+		items, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(items[0]).To(HaveKeyWithValue("lastUpdateTime", "2020-01-01T00:00:00Z"))
+
+		By("reporting no change when setting the same condition")
+		changed, err = SetCondition(obj, metav1.Condition{Type: "Available", Status: metav1.ConditionTrue, Reason: "Other", Message: "message"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeFalse())
+	})
+
+	It("bumps the lastTransitionTime when the status changes", func() {
+		changed, err := SetCondition(obj, metav1.Condition{Type: "Available", Status: metav1.ConditionFalse, Reason: "MinimumReplicasUnavailable"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+
+		condition, err := GetCondition(obj, "Available")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.LastTransitionTime.After(past.Time)).To(BeTrue())
+	})
+
+	It("removes conditions", func() {
+		changed, err := RemoveCondition(obj, "Progressing")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeFalse())
+
+		changed, err = RemoveCondition(obj, "Available")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(GetConditions(obj)).To(BeEmpty())
+	})
+
+	It("evaluates conditions", func() {
+		Expect(IsConditionTrue(obj, "Available")).To(BeTrue())
+		Expect(IsConditionTrue(obj, "Progressing")).To(BeFalse())
+		Expect(IsConditionStatus(obj, "Available", metav1.ConditionTrue)).To(BeTrue())
+		Expect(IsConditionStatus(obj, "Progressing", metav1.ConditionUnknown)).To(BeTrue())
+
+		By("ignoring stale conditions")
+		_, err := SetCondition(obj, metav1.Condition{Type: "Available", Status: metav1.ConditionTrue, ObservedGeneration: 1, Reason: "Ready"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(IsConditionTrue(obj, "Available")).To(BeFalse())
+		obj.SetGeneration(1)
+		Expect(IsConditionTrue(obj, "Available")).To(BeTrue())
+	})
+
+	It("patches the conditions through the status subresource", func() {
+		deploymentCli := dynClient.Resource(deploymentGVR)
+		deploy := &unstructured.Unstructured{}
+		deploy.SetGroupVersionKind(deploymentGVK)
+		deploy.SetName(obj.GetName())
+		deploy.SetNamespace(obj.GetNamespace())
+		_, err := CreateOrUpdate(context.TODO(), deploymentCli, deploy, deploymentSpecr(deploy, appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"foo": "bar"},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"foo": "bar"},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "busybox", Image: "busybox"}},
+				},
+			},
+		}))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = SetCondition(deploy, metav1.Condition{Type: "Example", Status: metav1.ConditionTrue, Reason: "Testing"})
+		Expect(err).NotTo(HaveOccurred())
+		op, err := PatchConditions(context.TODO(), deploymentCli, deploy)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdatedStatusOnly))
+
+		fetched, err := deploymentCli.Namespace(deploy.GetNamespace()).Get(context.TODO(), deploy.GetName(), metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(IsConditionTrue(fetched, "Example")).To(BeTrue())
+	})
+})
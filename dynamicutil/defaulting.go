@@ -0,0 +1,120 @@
+package dynamicutil
+
+import (
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DefaultingFunc sets the default values of the fields of obj in place, like
+// the API server does when the object is persisted.
+type DefaultingFunc func(obj runtime.Object)
+
+// WithDefaulting applies the defaulting functions registered in the scheme to
+// a copy of the mutated object before comparing it with the existing object,
+// so fields left empty by the MutateFn but defaulted by the API server don't
+// trigger an update. The object sent to the API server isn't defaulted.
+//
+// Unstructured objects are defaulted through the typed object registered in
+// the scheme for their kind, only the missing fields are filled in.
+//
+// The schemes of k8s.io/api and client-go register no defaulting functions,
+// so WithDefaulting(clientgoscheme.Scheme) defaults nothing. The defaults of
+// the workload kinds are registered by AddWorkloadDefaultingFuncs, or applied
+// by WithDefaultingFunc(DefaultWorkloads).
+func WithDefaulting(scheme *runtime.Scheme) Option {
+	return WithDefaultingFunc(schemeDefaulter(scheme))
+}
+
+// WithDefaultingFunc is WithDefaulting with a custom defaulting function
+func WithDefaultingFunc(f DefaultingFunc) Option {
+	return func(o *Options) {
+		o.Defaulter = f
+	}
+}
+
+// defaulted returns a defaulted copy of obj, or obj itself when there's no
+// defaulting function.
+func (o *Options) defaulted(obj runtime.Object) runtime.Object {
+	if o.Defaulter == nil {
+		return obj
+	}
+
+	desired := obj.DeepCopyObject()
+	o.Defaulter(desired)
+	return desired
+}
+
+func schemeDefaulter(scheme *runtime.Scheme) DefaultingFunc {
+	return func(obj runtime.Object) {
+		uns, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			scheme.Default(obj)
+			return
+		}
+
+		// Kinds which aren't registered, like most custom resources, have no
+		// defaulting functions.
+		typed, err := scheme.New(uns.GroupVersionKind())
+		if err != nil {
+			return
+		}
+		if err = unstructuredConverter.FromUnstructured(uns.Object, typed); err != nil {
+			return
+		}
+		undefaulted, err := unstructuredConverter.ToUnstructured(typed)
+		if err != nil {
+			return
+		}
+		scheme.Default(typed)
+		defaults, err := unstructuredConverter.ToUnstructured(typed)
+		if err != nil {
+			return
+		}
+		mergeDefaults(uns.Object, undefaulted, defaults)
+	}
+}
+
+// mergeDefaults copies to dst the fields of defaults missing in dst which
+// were set by the defaulting functions, i.e. which differ in undefaulted, the
+// typed object before defaulting. The empty values the typed object always
+// has, like a nil creationTimestamp or an empty status, aren't copied while
+// empty defaults like a pod securityContext are. Fields unknown to the typed
+// object are kept as they are.
+func mergeDefaults(dst, undefaulted, defaults map[string]interface{}) {
+	for k, defaultValue := range defaults {
+		undefaultedValue, wasSet := undefaulted[k]
+		value, ok := dst[k]
+		if !ok {
+			if !wasSet || !reflect.DeepEqual(undefaultedValue, defaultValue) {
+				dst[k] = runtime.DeepCopyJSONValue(defaultValue)
+			}
+			continue
+		}
+		mergeDefaultValues(value, undefaultedValue, defaultValue)
+	}
+}
+
+func mergeDefaultValues(value, undefaultedValue, defaultValue interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if d, ok := defaultValue.(map[string]interface{}); ok {
+			u, _ := undefaultedValue.(map[string]interface{})
+			mergeDefaults(v, u, d)
+		}
+	case []interface{}:
+		d, ok := defaultValue.([]interface{})
+		if !ok || len(d) != len(v) {
+			return
+		}
+		u, _ := undefaultedValue.([]interface{})
+		for i := range v {
+			var undefaultedElem interface{}
+			if i < len(u) {
+				undefaultedElem = u[i]
+			}
+			mergeDefaultValues(v[i], undefaultedElem, d[i])
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/dynamic"
+)
+
+var _ = Describe("Defaulting", func() {
+	var configMapCli dynamic.NamespaceableResourceInterface
+	var cm *corev1.ConfigMap
+	var cmUns *unstructured.Unstructured
+	var scheme *runtime.Scheme
+
+	// defaultConfigMap mimics a server-side default on the data of ConfigMaps
+	defaultConfigMap := func(obj interface{}) {
+		cm := obj.(*corev1.ConfigMap)
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		if _, ok := cm.Data["mode"]; !ok {
+			cm.Data["mode"] = "default"
+		}
+	}
+
+	BeforeEach(func() {
+		configMapCli = dynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+
+		cm = &corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("cm-%d", rand.Int31()),
+				Namespace: "default",
+			},
+		}
+		cmUns = &unstructured.Unstructured{}
+		cmUns.SetAPIVersion("v1")
+		cmUns.SetKind("ConfigMap")
+		cmUns.SetName(cm.Name)
+		cmUns.SetNamespace(cm.Namespace)
+
+		scheme = runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+		scheme.AddTypeDefaultingFunc(&corev1.ConfigMap{}, defaultConfigMap)
+
+		By("creating the object with the default value set")
+		existing := cm.DeepCopy()
+		_, err := CreateOrUpdate(context.TODO(), configMapCli, existing, configMapSetter(existing, "mode", "default"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("updates when the defaulted field is left empty without defaulting", func() {
+		op, err := CreateOrUpdate(context.TODO(), configMapCli, cm, func() error {
+			cm.Data = nil
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+	})
+
+	It("doesn't update when only defaulted fields differ (actual object)", func() {
+		op, err := CreateOrUpdate(context.TODO(), configMapCli, cm, func() error {
+			cm.Data = nil
+			return nil
+		}, WithDefaulting(scheme))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+	})
+
+	It("doesn't update when only defaulted fields differ (unstructured)", func() {
+		op, err := CreateOrPatch(context.TODO(), configMapCli, cmUns, func() error {
+			unstructured.RemoveNestedField(cmUns.Object, "data")
+			return nil
+		}, WithDefaulting(scheme))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+	})
+
+	It("updates when non defaulted fields differ", func() {
+		op, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"), WithDefaultingFunc(func(obj runtime.Object) {
+			defaultConfigMap(obj)
+		}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+
+		By("sending the object without defaults")
+		fetchedUns, err := configMapCli.Namespace(cm.Namespace).Get(context.TODO(), cm.Name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		data, _, err := unstructured.NestedStringMap(fetchedUns.Object, "data")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal(map[string]string{"foo": "bar"}))
+	})
+
+	It("doesn't update deployments defaulted by the API server on the next reconcile", func() {
+		deploymentCli := dynClient.Resource(deploymentGVR)
+		newDeploy := func() *appsv1.Deployment {
+			return &appsv1.Deployment{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+				ObjectMeta: metav1.ObjectMeta{Name: cm.Name, Namespace: "default"},
+			}
+		}
+		deplSpec := appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"foo": "bar"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"foo": "bar"}},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "busybox", Image: "busybox"}},
+				},
+			},
+		}
+
+		By("creating the object with the default values set")
+		deploy := newDeploy()
+		op, err := CreateOrUpdate(context.TODO(), deploymentCli, deploy, func() error {
+			deploy.Spec = *deplSpec.DeepCopy()
+			defaultDeployment(deploy)
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+		By("reconciling the spec without the default values")
+		deploy = newDeploy()
+		op, err = CreateOrUpdate(context.TODO(), deploymentCli, deploy, deploymentSpecr(deploy, deplSpec), WithDefaultingFunc(DefaultWorkloads))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+
+		By("reconciling the spec of the unstructured object")
+		deployUns := &unstructured.Unstructured{}
+		deployUns.SetGroupVersionKind(deploymentGVK)
+		deployUns.SetName(cm.Name)
+		deployUns.SetNamespace("default")
+		op, err = CreateOrPatch(context.TODO(), deploymentCli, deployUns, deploymentSpecr(deployUns, deplSpec), WithDefaultingFunc(DefaultWorkloads))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+	})
+
+	It("defaults the workload kinds", func() {
+		sts := &unstructured.Unstructured{}
+		sts.SetAPIVersion("apps/v1")
+		sts.SetKind("StatefulSet")
+		Expect(unstructured.SetNestedSlice(sts.Object, []interface{}{
+			map[string]interface{}{"name": "app", "image": "app:v1", "unknown": "kept"},
+		}, "spec", "template", "spec", "containers")).To(Succeed())
+
+		DefaultWorkloads(sts)
+		Expect(sts.Object).To(HaveKeyWithValue("spec", HaveKeyWithValue("podManagementPolicy", "OrderedReady")))
+		Expect(sts.Object).To(HaveKeyWithValue("spec", HaveKeyWithValue("revisionHistoryLimit", int64(10))))
+		containers, _, err := unstructured.NestedSlice(sts.Object, "spec", "template", "spec", "containers")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(containers).To(ConsistOf(And(
+			HaveKeyWithValue("imagePullPolicy", "IfNotPresent"),
+			HaveKeyWithValue("terminationMessagePath", "/dev/termination-log"),
+			HaveKeyWithValue("unknown", "kept"),
+		)))
+
+		By("leaving the other kinds unchanged")
+		other := cm.DeepCopy()
+		DefaultWorkloads(other)
+		Expect(other).To(Equal(cm))
+	})
+
+	table.DescribeTable("defaults the image pull policy from the tag",
+		func(image string, expected corev1.PullPolicy) {
+			Expect(defaultPullPolicy(image)).To(Equal(expected))
+		},
+		table.Entry("no tag", "busybox", corev1.PullAlways),
+		table.Entry("latest tag", "busybox:latest", corev1.PullAlways),
+		table.Entry("tag", "busybox:1.33", corev1.PullIfNotPresent),
+		table.Entry("registry port without tag", "localhost:5000/busybox", corev1.PullAlways),
+		table.Entry("registry port with tag", "localhost:5000/busybox:1.33", corev1.PullIfNotPresent),
+		table.Entry("digest", "busybox@sha256:0123", corev1.PullIfNotPresent),
+	)
+
+	It("keeps fields unknown to the typed object when merging defaults", func() {
+		dst := map[string]interface{}{
+			"unknown": "kept",
+			"spec": map[string]interface{}{
+				"list": []interface{}{map[string]interface{}{"name": "a"}},
+			},
+		}
+		mergeDefaults(dst, map[string]interface{}{
+			"status": map[string]interface{}{},
+			"spec": map[string]interface{}{
+				"list": []interface{}{map[string]interface{}{"name": "a"}},
+			},
+		}, map[string]interface{}{
+			"status": map[string]interface{}{},
+			"spec": map[string]interface{}{
+				"list":            []interface{}{map[string]interface{}{"name": "a", "policy": "Always"}},
+				"policy":          "Always",
+				"securityContext": map[string]interface{}{},
+			},
+		})
+		Expect(dst).To(Equal(map[string]interface{}{
+			"unknown": "kept",
+			"spec": map[string]interface{}{
+				"list":            []interface{}{map[string]interface{}{"name": "a", "policy": "Always"}},
+				"policy":          "Always",
+				"securityContext": map[string]interface{}{},
+			},
+		}))
+	})
+})
+
+// defaultDeployment mimics the defaults the API server sets on the fields of
+// Deployments left empty by deploymentSpecr, like the defaulters of
+// k8s.io/kubernetes do.
+func defaultDeployment(obj interface{}) {
+	deploy := obj.(*appsv1.Deployment)
+	spec := &deploy.Spec
+	if spec.Replicas == nil {
+		replicas := int32(1)
+		spec.Replicas = &replicas
+	}
+	if spec.RevisionHistoryLimit == nil {
+		revisionHistoryLimit := int32(10)
+		spec.RevisionHistoryLimit = &revisionHistoryLimit
+	}
+	if spec.ProgressDeadlineSeconds == nil {
+		progressDeadlineSeconds := int32(600)
+		spec.ProgressDeadlineSeconds = &progressDeadlineSeconds
+	}
+	if spec.Strategy.Type == "" {
+		spec.Strategy.Type = appsv1.RollingUpdateDeploymentStrategyType
+	}
+	if spec.Strategy.Type == appsv1.RollingUpdateDeploymentStrategyType && spec.Strategy.RollingUpdate == nil {
+		maxUnavailable := intstr.FromString("25%")
+		maxSurge := intstr.FromString("25%")
+		spec.Strategy.RollingUpdate = &appsv1.RollingUpdateDeployment{MaxUnavailable: &maxUnavailable, MaxSurge: &maxSurge}
+	}
+
+	podSpec := &spec.Template.Spec
+	if podSpec.RestartPolicy == "" {
+		podSpec.RestartPolicy = corev1.RestartPolicyAlways
+	}
+	if podSpec.TerminationGracePeriodSeconds == nil {
+		terminationGracePeriodSeconds := int64(corev1.DefaultTerminationGracePeriodSeconds)
+		podSpec.TerminationGracePeriodSeconds = &terminationGracePeriodSeconds
+	}
+	if podSpec.DNSPolicy == "" {
+		podSpec.DNSPolicy = corev1.DNSClusterFirst
+	}
+	if podSpec.SecurityContext == nil {
+		podSpec.SecurityContext = &corev1.PodSecurityContext{}
+	}
+	if podSpec.SchedulerName == "" {
+		podSpec.SchedulerName = corev1.DefaultSchedulerName
+	}
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		if c.TerminationMessagePath == "" {
+			c.TerminationMessagePath = corev1.TerminationMessagePathDefault
+		}
+		if c.TerminationMessagePolicy == "" {
+			c.TerminationMessagePolicy = corev1.TerminationMessageReadFile
+		}
+		if c.ImagePullPolicy == "" {
+			c.ImagePullPolicy = corev1.PullAlways
+			if !strings.HasSuffix(c.Image, ":latest") && strings.Contains(c.Image, ":") {
+				c.ImagePullPolicy = corev1.PullIfNotPresent
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package dynamicutil
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// DeleteOptions contains the options of a Delete call
+type DeleteOptions struct {
+	// PropagationPolicy tells whether and how the dependents of the object
+	// are garbage collected. Nil leaves the default policy of the resource.
+	PropagationPolicy *metav1.DeletionPropagation
+	// Preconditions must be fulfilled by the object for the deletion to
+	// proceed, a Conflict error is returned otherwise.
+	Preconditions *metav1.Preconditions
+	// Wait blocks until the object is actually gone, after its finalizers
+	// have run, or until the context is done.
+	Wait bool
+	// DryRun previews the deletion without persisting it, see DryRunMode.
+	DryRun DryRunMode
+}
+
+// Delete deletes the given object from the Kubernetes cluster. An object
+// which doesn't exist is not an error.
+//
+// It returns OperationResultDeleted, or OperationResultNone if the object
+// didn't exist, and an error.
+func Delete(ctx context.Context, c dynamic.NamespaceableResourceInterface, obj Object, opts DeleteOptions) (OperationResult, error) {
+	key := namespacedNameFromObject(obj)
+	cli := c.Namespace(key.Namespace)
+
+	if opts.DryRun == DryRunClient {
+		if _, err := cli.Get(ctx, key.Name, metav1.GetOptions{}); err != nil {
+			if apierrors.IsNotFound(err) {
+				return OperationResultNone, nil
+			}
+			return OperationResultNone, err
+		}
+		return OperationResultDeleted, nil
+	}
+
+	err := cli.Delete(ctx, key.Name, metav1.DeleteOptions{
+		DryRun:            dryRunOption(opts.DryRun),
+		PropagationPolicy: opts.PropagationPolicy,
+		Preconditions:     opts.Preconditions,
+	})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return OperationResultNone, nil
+		}
+		return OperationResultNone, err
+	}
+
+	if opts.Wait && opts.DryRun == DryRunNone {
+		uid := obj.GetUID()
+		if opts.Preconditions != nil && opts.Preconditions.UID != nil {
+			uid = *opts.Preconditions.UID
+		}
+		if err = waitForDeletion(ctx, cli, key.Name, uid); err != nil {
+			return OperationResultDeleted, err
+		}
+	}
+	return OperationResultDeleted, nil
+}
+
+// waitForDeletion blocks until the object with the given name and uid is
+// gone. An empty uid matches the object found when the wait starts, so an
+// object created again with the same name doesn't block it.
+func waitForDeletion(ctx context.Context, cli dynamic.ResourceInterface, name string, uid types.UID) error {
+	for {
+		fetchedUns, err := cli.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if uid == "" {
+			uid = fetchedUns.GetUID()
+		} else if fetchedUns.GetUID() != uid {
+			return nil
+		}
+
+		w, err := cli.Watch(ctx, metav1.ListOptions{
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", name).String(),
+			ResourceVersion: fetchedUns.GetResourceVersion(),
+		})
+		if err != nil {
+			return err
+		}
+		gone, err := watchForDeletion(ctx, w, uid)
+		w.Stop()
+		if err != nil || gone {
+			return err
+		}
+	}
+}
+
+// watchForDeletion tells whether the DELETED event of the object with the
+// given uid is received. It returns false when the watch is closed, or has
+// expired, and must be started again.
+func watchForDeletion(ctx context.Context, w watch.Interface, uid types.UID) (bool, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return false, nil
+			}
+			switch event.Type {
+			case watch.Deleted:
+				if obj, err := meta.Accessor(event.Object); err == nil && obj.GetUID() == uid {
+					return true, nil
+				}
+			case watch.Error:
+				err := apierrors.FromObject(event.Object)
+				if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+					return false, nil
+				}
+				return false, err
+			}
+		}
+	}
+}
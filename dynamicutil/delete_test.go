@@ -0,0 +1,149 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+var _ = Describe("Delete", func() {
+	var configMapCli dynamic.NamespaceableResourceInterface
+	var cm *corev1.ConfigMap
+
+	BeforeEach(func() {
+		configMapCli = dynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+
+		cm = &corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("cm-%d", rand.Int31()),
+				Namespace: "default",
+			},
+		}
+	})
+
+	isGone := func() bool {
+		_, err := configMapCli.Namespace(cm.Namespace).Get(context.TODO(), cm.Name, metav1.GetOptions{})
+		return apierrors.IsNotFound(err)
+	}
+
+	It("deletes an existing object", func() {
+		_, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"))
+		Expect(err).NotTo(HaveOccurred())
+
+		policy := metav1.DeletePropagationBackground
+		op, err := Delete(context.TODO(), configMapCli, cm, DeleteOptions{PropagationPolicy: &policy})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultDeleted))
+		Expect(isGone()).To(BeTrue())
+	})
+
+	It("returns OperationResultNone if the object doesn't exist", func() {
+		op, err := Delete(context.TODO(), configMapCli, cm, DeleteOptions{Wait: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+	})
+
+	It("doesn't delete the object if the preconditions aren't fulfilled", func() {
+		_, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"))
+		Expect(err).NotTo(HaveOccurred())
+
+		uid := types.UID("00000000-0000-0000-0000-000000000000")
+		_, err = Delete(context.TODO(), configMapCli, cm, DeleteOptions{Preconditions: &metav1.Preconditions{UID: &uid}})
+		Expect(apierrors.IsConflict(err)).To(BeTrue())
+		Expect(isGone()).To(BeFalse())
+	})
+
+	It("doesn't delete the object in client dry-run mode", func() {
+		_, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"))
+		Expect(err).NotTo(HaveOccurred())
+
+		op, err := Delete(context.TODO(), configMapCli, cm, DeleteOptions{DryRun: DryRunClient})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultDeleted))
+		Expect(isGone()).To(BeFalse())
+	})
+
+	It("waits for the finalizers of the object", func() {
+		_, err := CreateOrUpdate(context.TODO(), configMapCli, cm, func() error {
+			cm.Finalizers = []string{"example.com/test"}
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		done := make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			_, err := Delete(context.TODO(), configMapCli, cm.DeepCopy(), DeleteOptions{Wait: true})
+			done <- err
+		}()
+		Consistently(done, time.Second).ShouldNot(Receive())
+
+		By("removing the finalizer")
+		_, err = CreateOrPatch(context.TODO(), configMapCli, cm, func() error {
+			cm.Finalizers = nil
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(done, 10*time.Second).Should(Receive(BeNil()))
+		Expect(isGone()).To(BeTrue())
+	})
+
+	Describe("watchForDeletion", func() {
+		var w *watch.FakeWatcher
+		var deleted *corev1.ConfigMap
+
+		BeforeEach(func() {
+			w = watch.NewFakeWithChanSize(2, false)
+			deleted = cm.DeepCopy()
+			deleted.UID = "uid"
+		})
+
+		It("returns true on the DELETED event of the object", func() {
+			other := deleted.DeepCopy()
+			other.UID = "other"
+			w.Delete(other)
+			w.Delete(deleted)
+
+			gone, err := watchForDeletion(context.TODO(), w, "uid")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(gone).To(BeTrue())
+		})
+
+		It("returns false when the watch is closed or expired", func() {
+			w.Stop()
+			gone, err := watchForDeletion(context.TODO(), w, "uid")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(gone).To(BeFalse())
+
+			w = watch.NewFakeWithChanSize(1, false)
+			w.Error(&apierrors.NewResourceExpired("too old").ErrStatus)
+			gone, err = watchForDeletion(context.TODO(), w, "uid")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(gone).To(BeFalse())
+		})
+
+		It("returns the errors of the watch", func() {
+			w.Error(&apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, cm.Name, fmt.Errorf("denied")).ErrStatus)
+			_, err := watchForDeletion(context.TODO(), w, "uid")
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+		})
+
+		It("returns when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.TODO())
+			cancel()
+			_, err := watchForDeletion(ctx, w, "uid")
+			Expect(err).To(Equal(context.Canceled))
+		})
+	})
+})
@@ -0,0 +1,81 @@
+package dynamicutil
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// DryRunMode selects how the writes of an operation are previewed
+type DryRunMode string
+
+const (
+	// DryRunNone persists the writes
+	DryRunNone DryRunMode = ""
+	// DryRunServer sends the writes with dryRun=All, the API server runs
+	// admission and validation and returns the object it would persist
+	// without persisting it.
+	DryRunServer DryRunMode = "server"
+	// DryRunClient skips the writes entirely, the object is left as mutated
+	// by the MutateFn. The existing object is still fetched.
+	DryRunClient DryRunMode = "client"
+)
+
+// WithDryRun previews the operation: the would-be OperationResult is
+// returned and obj holds the object which would be persisted, but nothing is
+// persisted. See DryRunMode.
+func WithDryRun(mode DryRunMode) Option {
+	return func(o *Options) {
+		o.DryRun = mode
+	}
+}
+
+// dryRunOption returns the dryRun parameter of the requests sent in mode
+func dryRunOption(mode DryRunMode) []string {
+	if mode == DryRunServer {
+		return []string{metav1.DryRunAll}
+	}
+	return nil
+}
+
+// updateResult returns the result of updating an object and its status
+func updateResult(changed, statusChanged bool) OperationResult {
+	switch {
+	case changed && statusChanged:
+		return OperationResultUpdatedStatus
+	case changed:
+		return OperationResultUpdated
+	case statusChanged:
+		return OperationResultUpdatedStatusOnly
+	default:
+		return OperationResultNone
+	}
+}
+
+// withDryRunStatus returns a copy of updated with the status of
+// statusUpdated. A dry-run status update is made on the persisted object, not
+// on the result of the preceding dry-run update, so the two are combined.
+func withDryRunStatus(updated, statusUpdated *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	status, hasStatus, err := unstructured.NestedFieldNoCopy(statusUpdated.Object, "status")
+	if err != nil {
+		return nil, err
+	}
+	combined := updated.DeepCopy()
+	if err = setStatus(combined, status, hasStatus); err != nil {
+		return nil, err
+	}
+	return combined, nil
+}
+
+// dryRunChanged tells whether the dry-run result differs from the existing
+// object. The resourceVersion isn't bumped by a dry-run request, and the
+// timestamps of managedFields always are, so both are left out.
+func dryRunChanged(existing, result *unstructured.Unstructured) bool {
+	before := existing.DeepCopy()
+	after := result.DeepCopy()
+	for _, uns := range []*unstructured.Unstructured{before, after} {
+		uns.SetResourceVersion("")
+		uns.SetManagedFields(nil)
+	}
+	return !equality.Semantic.DeepEqual(before.Object, after.Object)
+}
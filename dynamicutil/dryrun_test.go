@@ -0,0 +1,137 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var _ = Describe("DryRun", func() {
+	var configMapCli dynamic.NamespaceableResourceInterface
+	var cm *corev1.ConfigMap
+
+	BeforeEach(func() {
+		configMapCli = dynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+
+		cm = &corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("cm-%d", rand.Int31()),
+				Namespace: "default",
+			},
+		}
+	})
+
+	fetchData := func() map[string]string {
+		fetchedUns, err := configMapCli.Namespace(cm.Namespace).Get(context.TODO(), cm.Name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		fetched := &corev1.ConfigMap{}
+		Expect(unstructuredConverter.FromUnstructured(fetchedUns.Object, fetched)).To(Succeed())
+		return fetched.Data
+	}
+
+	for _, mode := range []DryRunMode{DryRunServer, DryRunClient} {
+		mode := mode
+
+		Context(fmt.Sprintf("in %s mode", mode), func() {
+			It("doesn't create a new object", func() {
+				op, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"), WithDryRun(mode))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+				By("returning the object which would be created")
+				Expect(cm.Data).To(Equal(map[string]string{"foo": "bar"}))
+
+				By("not persisting it")
+				_, err = configMapCli.Namespace(cm.Namespace).Get(context.TODO(), cm.Name, metav1.GetOptions{})
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			})
+
+			It("doesn't update an existing object", func() {
+				_, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"))
+				Expect(err).NotTo(HaveOccurred())
+
+				op, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "baz"), WithDryRun(mode))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+				Expect(cm.Data).To(Equal(map[string]string{"foo": "baz"}))
+
+				Expect(fetchData()).To(Equal(map[string]string{"foo": "bar"}))
+			})
+
+			It("doesn't patch an existing object", func() {
+				_, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"))
+				Expect(err).NotTo(HaveOccurred())
+
+				op, err := CreateOrPatch(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "baz"), WithDryRun(mode))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+				Expect(cm.Data).To(Equal(map[string]string{"foo": "baz"}))
+
+				Expect(fetchData()).To(Equal(map[string]string{"foo": "bar"}))
+			})
+
+			It("returns OperationResultNone when unchanged", func() {
+				_, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"))
+				Expect(err).NotTo(HaveOccurred())
+
+				op, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"), WithDryRun(mode))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(op).To(BeEquivalentTo(OperationResultNone))
+			})
+
+			It("doesn't apply an object", func() {
+				applyOpts := ApplyOptions{FieldManager: "dynamicutil-test", DryRun: mode}
+				cm.Data = map[string]string{"foo": "bar"}
+				op, err := Apply(context.TODO(), configMapCli, cm.DeepCopy(), applyOpts)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+				_, err = configMapCli.Namespace(cm.Namespace).Get(context.TODO(), cm.Name, metav1.GetOptions{})
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+				By("comparing the applied fields with the existing object")
+				_, err = CreateOrUpdate(context.TODO(), configMapCli, cm.DeepCopy(), func() error { return nil })
+				Expect(err).NotTo(HaveOccurred())
+
+				op, err = Apply(context.TODO(), configMapCli, cm.DeepCopy(), applyOpts)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(op).To(BeEquivalentTo(OperationResultNone))
+
+				cm.Data = map[string]string{"foo": "baz"}
+				op, err = Apply(context.TODO(), configMapCli, cm.DeepCopy(), applyOpts)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+				Expect(fetchData()).To(Equal(map[string]string{"foo": "bar"}))
+			})
+		})
+	}
+
+	table.DescribeTable("tells whether the applied values are in the live object",
+		func(live, applied interface{}, expected bool) {
+			Expect(containsApplied(live, applied)).To(Equal(expected))
+		},
+		table.Entry("equal scalars", "a", "a", true),
+		table.Entry("different scalars", "a", "b", false),
+		table.Entry("numbers of different types", float64(1), int64(1), true),
+		table.Entry("null applied value", "a", nil, true),
+		table.Entry("fields missing from applied",
+			map[string]interface{}{"a": "1", "b": "2"}, map[string]interface{}{"a": "1"}, true),
+		table.Entry("fields missing from live",
+			map[string]interface{}{"a": "1"}, map[string]interface{}{"a": "1", "b": "2"}, false),
+		table.Entry("empty map missing from live", nil, map[string]interface{}{}, true),
+		table.Entry("list elements with defaulted fields",
+			[]interface{}{map[string]interface{}{"name": "a", "policy": "Always"}},
+			[]interface{}{map[string]interface{}{"name": "a"}}, true),
+		table.Entry("lists of different lengths",
+			[]interface{}{"a", "b"}, []interface{}{"a"}, false),
+	)
+})
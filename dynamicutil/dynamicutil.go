@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 
+	jsonpatch "github.com/evanphx/json-patch"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -28,6 +29,16 @@ const ( // They should complete the sentence "Deployment default/foo has been ..
 	OperationResultCreated OperationResult = "created"
 	// OperationResultUpdated means that an existing resource is updated
 	OperationResultUpdated OperationResult = "updated"
+	// OperationResultUpdatedStatus means that an existing resource and its status is updated
+	OperationResultUpdatedStatus OperationResult = "updatedStatus"
+	// OperationResultUpdatedStatusOnly means that only an existing status is updated
+	OperationResultUpdatedStatusOnly OperationResult = "updatedStatusOnly"
+	// OperationResultDeleted means that an existing resource is deleted
+	OperationResultDeleted OperationResult = "deleted"
+	// OperationResultRecreated means that an existing resource is deleted and created again
+	OperationResultRecreated OperationResult = "recreated"
+	// OperationResultAdopted means that an existing resource not managed by the manager is updated
+	OperationResultAdopted OperationResult = "adopted"
 )
 
 type Object interface {
@@ -59,9 +70,21 @@ type MutateFn func() error
 // state inside the passed in callback MutateFn.
 //
 // The MutateFn is called regardless of creating or updating an object.
+// With the WithRetry option, conflicts and transient errors are retried, with
+// the WithDryRun option nothing is persisted.
+//
+// Fields of the existing object unknown to the type of a typed obj are kept
+// by the update.
 //
 // It returns the executed operation and an error.
-func CreateOrUpdate(ctx context.Context, c dynamic.NamespaceableResourceInterface, obj Object, f MutateFn) (OperationResult, error) {
+func CreateOrUpdate(ctx context.Context, c dynamic.NamespaceableResourceInterface, obj Object, f MutateFn, opts ...Option) (OperationResult, error) {
+	o := newOptions(opts)
+	return retry(ctx, o.Backoff, func() (OperationResult, error) {
+		return createOrUpdate(ctx, c, obj, f, o)
+	})
+}
+
+func createOrUpdate(ctx context.Context, c dynamic.NamespaceableResourceInterface, obj Object, f MutateFn, o *Options) (OperationResult, error) {
 	key := namespacedNameFromObject(obj)
 	cli := c.Namespace(key.Namespace)
 
@@ -70,47 +93,313 @@ func CreateOrUpdate(ctx context.Context, c dynamic.NamespaceableResourceInterfac
 		if !apierrors.IsNotFound(err) {
 			return OperationResultNone, err
 		}
+		return create(ctx, cli, key, obj, f, o)
+	}
 
-		if err = mutate(f, key, obj); err != nil {
-			return OperationResultNone, err
+	if err = objectFromUnstructured(fetchedUns, obj); err != nil {
+		return OperationResultNone, err
+	}
+	adopt, err := o.checkOwnership(obj)
+	if err != nil {
+		return OperationResultNone, err
+	}
+
+	existing := obj.DeepCopyObject()
+	if err = mutate(f, key, obj); err != nil {
+		return OperationResultNone, err
+	}
+	o.stampManager(obj)
+	if err = o.reportChanges(existing, obj); err != nil {
+		return OperationResultNone, err
+	}
+	changed, statusChanged, err := o.compare(existing, obj)
+	if err != nil || (!changed && !statusChanged) {
+		return OperationResultNone, err
+	}
+	if o.DryRun == DryRunClient {
+		return adoptedResult(updateResult(changed, statusChanged), adopt), nil
+	}
+
+	sent, err := o.ignoring(existing, obj)
+	if err != nil {
+		return OperationResultNone, err
+	}
+	_, status, hasStatus, err := splitStatus(sent)
+	if err != nil {
+		return OperationResultNone, err
+	}
+	// The update replaces the whole object, so the fields unknown to the
+	// typed object must be sent back.
+	objUns, err := o.replacementObject(fetchedUns, existing, sent)
+	if err != nil {
+		return OperationResultNone, err
+	}
+
+	result := OperationResultNone
+	if changed {
+		if fetchedUns, err = cli.Update(ctx, objUns, metav1.UpdateOptions{DryRun: dryRunOption(o.DryRun)}); err != nil {
+			if o.RecreateOnImmutableFields && isImmutableFieldError(err) {
+				return recreate(ctx, c, objUns, obj, o)
+			}
+			return result, err
 		}
+		result = OperationResultUpdated
 
-		objUns, err := unstructuredFromObject(obj)
+		// Resources without a status subresource have their status persisted
+		// by the update itself.
+		updatedStatus, hasUpdatedStatus, err := unstructured.NestedFieldNoCopy(fetchedUns.Object, "status")
 		if err != nil {
-			return OperationResultNone, err
+			return result, err
 		}
-		if fetchedUns, err = cli.Create(ctx, objUns, metav1.CreateOptions{}); err != nil {
-			return OperationResultNone, err
+		if hasUpdatedStatus == hasStatus && equality.Semantic.DeepEqual(updatedStatus, status) {
+			statusChanged = false
+		}
+	}
+
+	if statusChanged {
+		statusUns := objUns
+		if result == OperationResultUpdated {
+			statusUns = fetchedUns.DeepCopy()
+			if err = setStatus(statusUns, status, hasStatus); err != nil {
+				return result, err
+			}
+		}
+		updatedUns := fetchedUns
+		if fetchedUns, err = cli.UpdateStatus(ctx, statusUns, metav1.UpdateOptions{DryRun: dryRunOption(o.DryRun)}); err != nil {
+			return result, err
+		}
+		if o.DryRun == DryRunServer && result == OperationResultUpdated {
+			if fetchedUns, err = withDryRunStatus(updatedUns, fetchedUns); err != nil {
+				return result, err
+			}
 		}
-		if err = objectFromUnstructured(fetchedUns, obj); err != nil {
+		if result == OperationResultUpdated {
+			result = OperationResultUpdatedStatus
+		} else {
+			result = OperationResultUpdatedStatusOnly
+		}
+	}
+
+	if err = objectFromUnstructured(fetchedUns, obj); err != nil {
+		return result, err
+	}
+	return adoptedResult(result, adopt), nil
+}
+
+// CreateOrPatch creates or patches the given object in the Kubernetes
+// cluster. The object's desired state must be reconciled with the before
+// state inside the passed in callback MutateFn.
+//
+// Unlike CreateOrUpdate, only the fields changed by the MutateFn are sent to
+// the API server, as a JSON merge patch.
+//
+// The MutateFn is called regardless of creating or updating an object.
+// With the WithRetry option, conflicts and transient errors are retried, with
+// the WithDryRun option nothing is persisted.
+//
+// It returns the executed operation and an error.
+func CreateOrPatch(ctx context.Context, c dynamic.NamespaceableResourceInterface, obj Object, f MutateFn, opts ...Option) (OperationResult, error) {
+	o := newOptions(opts)
+	return retry(ctx, o.Backoff, func() (OperationResult, error) {
+		return createOrPatch(ctx, c, obj, f, o)
+	})
+}
+
+func createOrPatch(ctx context.Context, c dynamic.NamespaceableResourceInterface, obj Object, f MutateFn, o *Options) (OperationResult, error) {
+	key := namespacedNameFromObject(obj)
+	cli := c.Namespace(key.Namespace)
+
+	fetchedUns, err := cli.Get(ctx, key.Name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
 			return OperationResultNone, err
 		}
-		return OperationResultCreated, nil
+		return create(ctx, cli, key, obj, f, o)
 	}
 
 	if err = objectFromUnstructured(fetchedUns, obj); err != nil {
 		return OperationResultNone, err
 	}
+	adopt, err := o.checkOwnership(obj)
+	if err != nil {
+		return OperationResultNone, err
+	}
 
 	existing := obj.DeepCopyObject()
+	before, beforeStatus, hasBeforeStatus, err := splitStatus(existing)
+	if err != nil {
+		return OperationResultNone, err
+	}
 	if err = mutate(f, key, obj); err != nil {
 		return OperationResultNone, err
 	}
-	if equality.Semantic.DeepEqual(existing, obj) {
-		return OperationResultNone, nil
+	o.stampManager(obj)
+	if err = o.reportChanges(existing, obj); err != nil {
+		return OperationResultNone, err
+	}
+	changed, statusChanged, err := o.compare(existing, obj)
+	if err != nil || (!changed && !statusChanged) {
+		return OperationResultNone, err
+	}
+	if o.DryRun == DryRunClient {
+		return adoptedResult(updateResult(changed, statusChanged), adopt), nil
+	}
+	sent, err := o.ignoring(existing, obj)
+	if err != nil {
+		return OperationResultNone, err
+	}
+	after, afterStatus, hasAfterStatus, err := splitStatus(sent)
+	if err != nil {
+		return OperationResultNone, err
 	}
 
-	objUns, err := unstructuredFromObject(obj)
+	result := OperationResultNone
+	if changed {
+		patch, err := createMergePatch(before, after)
+		if err != nil {
+			return result, err
+		}
+		liveUns := fetchedUns
+		if fetchedUns, err = cli.Patch(ctx, key.Name, types.MergePatchType, patch, metav1.PatchOptions{DryRun: dryRunOption(o.DryRun)}); err != nil {
+			if o.RecreateOnImmutableFields && isImmutableFieldError(err) {
+				// The live object is deleted, so the fields unknown to the
+				// typed object would be lost for good.
+				desired, err := o.replacementObject(liveUns, existing, sent)
+				if err != nil {
+					return result, err
+				}
+				return recreate(ctx, c, desired, obj, o)
+			}
+			return result, err
+		}
+		result = OperationResultUpdated
+	}
+
+	if statusChanged {
+		beforeUns := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		if err = setStatus(beforeUns, beforeStatus, hasBeforeStatus); err != nil {
+			return result, err
+		}
+		afterUns := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		if err = setStatus(afterUns, afterStatus, hasAfterStatus); err != nil {
+			return result, err
+		}
+		patch, err := createMergePatch(beforeUns, afterUns)
+		if err != nil {
+			return result, err
+		}
+		patchedUns := fetchedUns
+		if fetchedUns, err = cli.Patch(ctx, key.Name, types.MergePatchType, patch, metav1.PatchOptions{DryRun: dryRunOption(o.DryRun)}, "status"); err != nil {
+			return result, err
+		}
+		if o.DryRun == DryRunServer && result == OperationResultUpdated {
+			if fetchedUns, err = withDryRunStatus(patchedUns, fetchedUns); err != nil {
+				return result, err
+			}
+		}
+		if result == OperationResultUpdated {
+			result = OperationResultUpdatedStatus
+		} else {
+			result = OperationResultUpdatedStatusOnly
+		}
+	}
+
+	if err = objectFromUnstructured(fetchedUns, obj); err != nil {
+		return result, err
+	}
+	return adoptedResult(result, adopt), nil
+}
+
+// create mutates obj and creates it, it's the common path of CreateOrUpdate
+// and CreateOrPatch when the object doesn't exist yet.
+func create(ctx context.Context, cli dynamic.ResourceInterface, key types.NamespacedName, obj Object, f MutateFn, o *Options) (OperationResult, error) {
+	initial := obj.DeepCopyObject()
+	if err := mutate(f, key, obj); err != nil {
+		return OperationResultNone, err
+	}
+	o.stampManager(obj)
+	if err := o.reportChanges(initial, obj); err != nil {
+		return OperationResultNone, err
+	}
+
+	if o.DryRun == DryRunClient {
+		return OperationResultCreated, nil
+	}
+
+	objUns, err := stampedUnstructuredFromObject(o.Scheme, obj)
 	if err != nil {
 		return OperationResultNone, err
 	}
-	if fetchedUns, err = cli.Update(ctx, objUns, metav1.UpdateOptions{}); err != nil {
+	fetchedUns, err := cli.Create(ctx, objUns, metav1.CreateOptions{DryRun: dryRunOption(o.DryRun)})
+	if err != nil {
 		return OperationResultNone, err
 	}
 	if err = objectFromUnstructured(fetchedUns, obj); err != nil {
 		return OperationResultNone, err
 	}
-	return OperationResultUpdated, nil
+	return OperationResultCreated, nil
+}
+
+// compare tells whether obj, and its status, differ from existing. The
+// comparison options are applied to obj beforehand.
+func (o *Options) compare(existing, obj runtime.Object) (changed bool, statusChanged bool, err error) {
+	before, beforeStatus, hasBeforeStatus, err := splitStatus(existing)
+	if err != nil {
+		return false, false, err
+	}
+	desired, err := o.ignoring(existing, o.defaulted(obj))
+	if err != nil {
+		return false, false, err
+	}
+	after, afterStatus, hasAfterStatus, err := splitStatus(desired)
+	if err != nil {
+		return false, false, err
+	}
+
+	changed = !equality.Semantic.DeepEqual(before, after)
+	statusChanged = (hasBeforeStatus || hasAfterStatus) && !equality.Semantic.DeepEqual(beforeStatus, afterStatus)
+	return changed, statusChanged, nil
+}
+
+// splitStatus returns a copy of the object without its status, along with
+// the status itself and whether it is present
+func splitStatus(obj runtime.Object) (*unstructured.Unstructured, interface{}, bool, error) {
+	objUns, err := unstructuredFromObject(obj)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	content := objUns.DeepCopy()
+	status, hasStatus, err := unstructured.NestedFieldNoCopy(content.Object, "status")
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if hasStatus {
+		unstructured.RemoveNestedField(content.Object, "status")
+	}
+	return content, status, hasStatus, nil
+}
+
+// setStatus replaces the status of uns, removing it if hasStatus is false
+func setStatus(uns *unstructured.Unstructured, status interface{}, hasStatus bool) error {
+	if !hasStatus {
+		unstructured.RemoveNestedField(uns.Object, "status")
+		return nil
+	}
+	return unstructured.SetNestedField(uns.Object, runtime.DeepCopyJSONValue(status), "status")
+}
+
+// createMergePatch returns the JSON merge patch which transforms before into after
+func createMergePatch(before, after *unstructured.Unstructured) ([]byte, error) {
+	beforeJSON, err := before.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	afterJSON, err := after.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return jsonpatch.CreateMergePatch(beforeJSON, afterJSON)
 }
 
 func unstructuredFromObject(obj runtime.Object) (*unstructured.Unstructured, error) {
@@ -127,6 +416,25 @@ func unstructuredFromObject(obj runtime.Object) (*unstructured.Unstructured, err
 	return &unstructured.Unstructured{Object: m}, nil
 }
 
+// stampedUnstructuredFromObject is unstructuredFromObject with the GVK of
+// typed objects looked up in the scheme, if any.
+func stampedUnstructuredFromObject(scheme *runtime.Scheme, obj runtime.Object) (*unstructured.Unstructured, error) {
+	uns, err := unstructuredFromObject(obj)
+	if err != nil || scheme == nil {
+		return uns, err
+	}
+	if _, ok := obj.(*unstructured.Unstructured); ok {
+		return uns, nil
+	}
+
+	gvk, err := gvkForObject(scheme, obj)
+	if err != nil {
+		return nil, err
+	}
+	uns.SetGroupVersionKind(gvk)
+	return uns, nil
+}
+
 func objectFromUnstructured(uns *unstructured.Unstructured, obj runtime.Object) error {
 	objUns, ok := obj.(*unstructured.Unstructured)
 	if ok {
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"math/rand"
 
-	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -248,6 +247,53 @@ var _ = Describe("Dynamicutil", func() {
 			Expect(op).To(BeEquivalentTo(OperationResultNone))
 		})
 
+		It("updates only status of existing object", func() {
+			op, err := CreateOrUpdate(context.TODO(), deploymentCli, deploy, specrActual)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+			op, err = CreateOrUpdate(context.TODO(), deploymentCli, deploy, deploymentStatusr(deploy, 1))
+			By("returning no error")
+			Expect(err).NotTo(HaveOccurred())
+
+			By("returning OperationResultUpdatedStatusOnly")
+			Expect(op).To(BeEquivalentTo(OperationResultUpdatedStatusOnly))
+
+			By("actually having the deployment status updated")
+			fetchedUns, err := deploymentCli.Namespace(deplKey.Namespace).Get(context.TODO(), deplKey.Name, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			replicas, _, err := unstructured.NestedInt64(fetchedUns.Object, "status", "replicas")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replicas).To(BeEquivalentTo(1))
+		})
+
+		It("updates existing object and its status", func() {
+			var scale int32 = 2
+			op, err := CreateOrUpdate(context.TODO(), deploymentCli, deploy, specrActual)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+			op, err = CreateOrUpdate(context.TODO(), deploymentCli, deploy, func() error {
+				Expect(deploymentScaler(deploy, scale)()).To(Succeed())
+				return deploymentStatusr(deploy, scale)()
+			})
+			By("returning no error")
+			Expect(err).NotTo(HaveOccurred())
+
+			By("returning OperationResultUpdatedStatus")
+			Expect(op).To(BeEquivalentTo(OperationResultUpdatedStatus))
+
+			By("actually having the deployment and its status updated")
+			fetchedUns, err := deploymentCli.Namespace(deplKey.Namespace).Get(context.TODO(), deplKey.Name, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			fetched := &appsv1.Deployment{}
+			err = unstructuredConverter.FromUnstructured(fetchedUns.Object, fetched)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*fetched.Spec.Replicas).To(Equal(scale))
+			Expect(fetched.Status.Replicas).To(Equal(scale))
+		})
+
 		It("aborts immediately if there was an error initially retrieving the object", func() {
 			op, err := CreateOrUpdate(context.TODO(), namespaceableErrorReader{deploymentCli}, deployUns, func() error {
 				Fail("Mutation method should not run")
@@ -258,6 +304,200 @@ var _ = Describe("Dynamicutil", func() {
 			Expect(err).To(HaveOccurred())
 		})
 	})
+
+	Describe("CreateOrPatch", func() {
+		var deploymentCli dynamic.NamespaceableResourceInterface
+		var deploy *appsv1.Deployment
+		var deployUns *unstructured.Unstructured
+		var deplKey types.NamespacedName
+		var specrActual MutateFn
+		var specrUns MutateFn
+
+		BeforeEach(func() {
+			deploymentCli = dynClient.Resource(deploymentGVR)
+
+			deploy = &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("deploy-%d", rand.Int31()),
+					Namespace: "default",
+				},
+			}
+
+			deployUns = &unstructured.Unstructured{}
+			deployUns.SetName(deploy.Name)
+			deployUns.SetNamespace(deploy.Namespace)
+			deployUns.SetGroupVersionKind(deploymentGVK)
+
+			deplSpec := appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"foo": "bar"},
+				},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{"foo": "bar"},
+					},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "busybox", Image: "busybox"}},
+					},
+				},
+			}
+
+			deplKey = types.NamespacedName{
+				Name:      deployUns.GetName(),
+				Namespace: deployUns.GetNamespace(),
+			}
+
+			specrActual = deploymentSpecr(deploy, deplSpec)
+			specrUns = deploymentSpecr(deployUns, deplSpec)
+		})
+
+		It("creates a new object if one doesn't exists", func() {
+			op, err := CreateOrPatch(context.TODO(), deploymentCli, deployUns, specrUns)
+
+			By("returning no error")
+			Expect(err).NotTo(HaveOccurred())
+
+			By("returning OperationResultCreated")
+			Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+			By("actually having the deployment created")
+			fetchedUns, err := deploymentCli.Namespace(deplKey.Namespace).Get(context.TODO(), deplKey.Name, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetchedUns.Object).To(Equal(deployUns.Object))
+		})
+
+		It("patches existing object (actual object)", func() {
+			var scale int32 = 2
+			op, err := CreateOrPatch(context.TODO(), deploymentCli, deploy, specrActual)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+			op, err = CreateOrPatch(context.TODO(), deploymentCli, deploy, deploymentScaler(deploy, scale))
+			By("returning no error")
+			Expect(err).NotTo(HaveOccurred())
+
+			By("returning OperationResultUpdated")
+			Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+
+			By("actually having the deployment scaled")
+			fetchedUns, err := deploymentCli.Namespace(deplKey.Namespace).Get(context.TODO(), deplKey.Name, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			fetched := &appsv1.Deployment{}
+			err = unstructuredConverter.FromUnstructured(fetchedUns.Object, fetched)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*fetched.Spec.Replicas).To(Equal(scale))
+
+			By("returned should equal to fetched")
+			Expect(fetched).To(Equal(deploy))
+		})
+
+		It("patches existing object (unstructured)", func() {
+			var scale int32 = 2
+			op, err := CreateOrPatch(context.TODO(), deploymentCli, deployUns, specrUns)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+			op, err = CreateOrPatch(context.TODO(), deploymentCli, deployUns, deploymentScalerUnstructured(deployUns, scale))
+			By("returning no error")
+			Expect(err).NotTo(HaveOccurred())
+
+			By("returning OperationResultUpdated")
+			Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+
+			By("actually having the deployment scaled")
+			replicas, found, err := unstructured.NestedInt64(deployUns.Object, "spec", "replicas")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(replicas).To(BeEquivalentTo(scale))
+		})
+
+		It("patches only changed objects", func() {
+			op, err := CreateOrPatch(context.TODO(), deploymentCli, deployUns, specrUns)
+
+			Expect(op).To(BeEquivalentTo(OperationResultCreated))
+			Expect(err).NotTo(HaveOccurred())
+
+			op, err = CreateOrPatch(context.TODO(), deploymentCli, deployUns, deploymentIdentity)
+			By("returning no error")
+			Expect(err).NotTo(HaveOccurred())
+
+			By("returning OperationResultNone")
+			Expect(op).To(BeEquivalentTo(OperationResultNone))
+		})
+
+		It("errors when MutateFn renames an object", func() {
+			op, err := CreateOrPatch(context.TODO(), deploymentCli, deployUns, specrUns)
+
+			Expect(op).To(BeEquivalentTo(OperationResultCreated))
+			Expect(err).NotTo(HaveOccurred())
+
+			op, err = CreateOrPatch(context.TODO(), deploymentCli, deployUns, deploymentRenamer(deployUns))
+
+			By("returning error")
+			Expect(err).To(HaveOccurred())
+
+			By("returning OperationResultNone")
+			Expect(op).To(BeEquivalentTo(OperationResultNone))
+		})
+
+		It("patches only status of existing object", func() {
+			op, err := CreateOrPatch(context.TODO(), deploymentCli, deploy, specrActual)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+			op, err = CreateOrPatch(context.TODO(), deploymentCli, deploy, deploymentStatusr(deploy, 1))
+			By("returning no error")
+			Expect(err).NotTo(HaveOccurred())
+
+			By("returning OperationResultUpdatedStatusOnly")
+			Expect(op).To(BeEquivalentTo(OperationResultUpdatedStatusOnly))
+
+			By("actually having the deployment status updated")
+			fetchedUns, err := deploymentCli.Namespace(deplKey.Namespace).Get(context.TODO(), deplKey.Name, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			replicas, _, err := unstructured.NestedInt64(fetchedUns.Object, "status", "replicas")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replicas).To(BeEquivalentTo(1))
+		})
+
+		It("patches existing object and its status", func() {
+			var scale int32 = 2
+			op, err := CreateOrPatch(context.TODO(), deploymentCli, deploy, specrActual)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+			op, err = CreateOrPatch(context.TODO(), deploymentCli, deploy, func() error {
+				Expect(deploymentScaler(deploy, scale)()).To(Succeed())
+				return deploymentStatusr(deploy, scale)()
+			})
+			By("returning no error")
+			Expect(err).NotTo(HaveOccurred())
+
+			By("returning OperationResultUpdatedStatus")
+			Expect(op).To(BeEquivalentTo(OperationResultUpdatedStatus))
+
+			By("actually having the deployment and its status updated")
+			fetchedUns, err := deploymentCli.Namespace(deplKey.Namespace).Get(context.TODO(), deplKey.Name, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			fetched := &appsv1.Deployment{}
+			err = unstructuredConverter.FromUnstructured(fetchedUns.Object, fetched)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*fetched.Spec.Replicas).To(Equal(scale))
+			Expect(fetched.Status.Replicas).To(Equal(scale))
+		})
+
+		It("aborts immediately if there was an error initially retrieving the object", func() {
+			op, err := CreateOrPatch(context.TODO(), namespaceableErrorReader{deploymentCli}, deployUns, func() error {
+				Fail("Mutation method should not run")
+				return nil
+			})
+
+			Expect(op).To(BeEquivalentTo(OperationResultNone))
+			Expect(err).To(HaveOccurred())
+		})
+	})
 })
 
 var deploymentIdentity MutateFn = func() error {
@@ -313,6 +553,13 @@ func deploymentScaler(deploy runtime.Object, replicas int32) MutateFn {
 	}
 }
 
+func deploymentStatusr(deploy *appsv1.Deployment, replicas int32) MutateFn {
+	return func() error {
+		deploy.Status.Replicas = replicas
+		return nil
+	}
+}
+
 func deploymentScalerUnstructured(deploy *unstructured.Unstructured, replicas int32) MutateFn {
 	return func() error {
 		intReplicas := int64(replicas)
@@ -0,0 +1,134 @@
+package dynamicutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+// finalizerBackoff is the backoff of the finalizer patches which race with
+// other writers
+var finalizerBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 10 * time.Millisecond,
+	Factor:   2.0,
+	Jitter:   0.1,
+}
+
+// AddFinalizer adds the finalizer to the given object in the Kubernetes
+// cluster, it's a no-op if the object already has it. The finalizers are
+// changed with a JSON patch which fails if they were changed concurrently,
+// in which case it's retried.
+//
+// On success the patched object is decoded back into obj. It returns
+// OperationResultUpdated, or OperationResultNone if the object already has
+// the finalizer, and an error.
+func AddFinalizer(ctx context.Context, c dynamic.NamespaceableResourceInterface, obj Object, finalizer string) (OperationResult, error) {
+	return retry(ctx, &finalizerBackoff, func() (OperationResult, error) {
+		return patchFinalizers(ctx, c, obj, func(finalizers []string) []map[string]interface{} {
+			switch {
+			case containsString(finalizers, finalizer):
+				return nil
+			case len(finalizers) == 0:
+				return []map[string]interface{}{{"op": "add", "path": "/metadata/finalizers", "value": []string{finalizer}}}
+			default:
+				return []map[string]interface{}{{"op": "add", "path": "/metadata/finalizers/-", "value": finalizer}}
+			}
+		})
+	})
+}
+
+// RemoveFinalizer removes the finalizer from the given object in the
+// Kubernetes cluster, it's a no-op if the object doesn't have it, see
+// AddFinalizer.
+//
+// It returns OperationResultUpdated, or OperationResultNone if the object
+// doesn't have the finalizer, and an error.
+func RemoveFinalizer(ctx context.Context, c dynamic.NamespaceableResourceInterface, obj Object, finalizer string) (OperationResult, error) {
+	return retry(ctx, &finalizerBackoff, func() (OperationResult, error) {
+		return patchFinalizers(ctx, c, obj, func(finalizers []string) []map[string]interface{} {
+			// The finalizer is removed from the end so the indices of the
+			// other occurrences are still valid.
+			var ops []map[string]interface{}
+			for i := len(finalizers) - 1; i >= 0; i-- {
+				if finalizers[i] == finalizer {
+					ops = append(ops, map[string]interface{}{"op": "remove", "path": appendPointer("/metadata/finalizers", strconv.Itoa(i))})
+				}
+			}
+			return ops
+		})
+	})
+}
+
+// ContainsFinalizer tells whether obj has the finalizer
+func ContainsFinalizer(obj metav1.Object, finalizer string) bool {
+	return containsString(obj.GetFinalizers(), finalizer)
+}
+
+// patchFinalizers patches the finalizers of the object with the operations
+// returned by ops for its current finalizers. The patch is preceded by a test
+// of the current finalizers, a Conflict error is returned if it fails.
+func patchFinalizers(ctx context.Context, c dynamic.NamespaceableResourceInterface, obj Object, ops func(finalizers []string) []map[string]interface{}) (OperationResult, error) {
+	key := namespacedNameFromObject(obj)
+	cli := c.Namespace(key.Namespace)
+
+	fetchedUns, err := cli.Get(ctx, key.Name, metav1.GetOptions{})
+	if err != nil {
+		return OperationResultNone, err
+	}
+	finalizers := fetchedUns.GetFinalizers()
+	changes := ops(finalizers)
+	if len(changes) == 0 {
+		return OperationResultNone, objectFromUnstructured(fetchedUns, obj)
+	}
+
+	// Missing finalizers are tested against null
+	var current interface{}
+	if len(finalizers) > 0 {
+		current = finalizers
+	}
+	patch := append([]map[string]interface{}{{"op": "test", "path": "/metadata/finalizers", "value": current}}, changes...)
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return OperationResultNone, err
+	}
+
+	gvk := fetchedUns.GroupVersionKind()
+	fetchedUns, err = cli.Patch(ctx, key.Name, types.JSONPatchType, data, metav1.PatchOptions{})
+	if err != nil {
+		if isTestFailed(err) {
+			return OperationResultNone, apierrors.NewConflict(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, key.Name, err)
+		}
+		return OperationResultNone, err
+	}
+	if err = objectFromUnstructured(fetchedUns, obj); err != nil {
+		return OperationResultUpdated, err
+	}
+	return OperationResultUpdated, nil
+}
+
+// isTestFailed tells whether a JSON patch failed because of a test operation,
+// the error is only a message when returned by the API server.
+func isTestFailed(err error) bool {
+	return errors.Is(err, jsonpatch.ErrTestFailed) || strings.Contains(err.Error(), "testing value")
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+var _ = Describe("Finalizers", func() {
+	var configMapCli dynamic.NamespaceableResourceInterface
+	var cm *corev1.ConfigMap
+
+	BeforeEach(func() {
+		configMapCli = dynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+
+		cm = &corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("cm-%d", rand.Int31()),
+				Namespace: "default",
+			},
+		}
+		_, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		// The finalizers would keep the object once deleted
+		_, err := CreateOrPatch(context.TODO(), configMapCli, cm, func() error {
+			cm.Finalizers = nil
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	fetchFinalizers := func() []string {
+		fetchedUns, err := configMapCli.Namespace(cm.Namespace).Get(context.TODO(), cm.Name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		return fetchedUns.GetFinalizers()
+	}
+
+	It("adds and removes finalizers", func() {
+		op, err := AddFinalizer(context.TODO(), configMapCli, cm, "example.com/a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+		Expect(ContainsFinalizer(cm, "example.com/a")).To(BeTrue())
+
+		op, err = AddFinalizer(context.TODO(), configMapCli, cm, "example.com/b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+		Expect(fetchFinalizers()).To(Equal([]string{"example.com/a", "example.com/b"}))
+
+		op, err = RemoveFinalizer(context.TODO(), configMapCli, cm, "example.com/a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+		Expect(ContainsFinalizer(cm, "example.com/a")).To(BeFalse())
+		Expect(fetchFinalizers()).To(Equal([]string{"example.com/b"}))
+	})
+
+	It("is idempotent", func() {
+		_, err := AddFinalizer(context.TODO(), configMapCli, cm, "example.com/a")
+		Expect(err).NotTo(HaveOccurred())
+
+		op, err := AddFinalizer(context.TODO(), configMapCli, cm, "example.com/a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+		Expect(fetchFinalizers()).To(Equal([]string{"example.com/a"}))
+
+		op, err = RemoveFinalizer(context.TODO(), configMapCli, cm, "example.com/b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+	})
+
+	It("keeps the finalizers added concurrently", func() {
+		_, err := AddFinalizer(context.TODO(), configMapCli, cm, "example.com/a")
+		Expect(err).NotTo(HaveOccurred())
+
+		racing := &racingFinalizerWriter{NamespaceableResourceInterface: configMapCli, finalizer: "example.com/other"}
+		op, err := RemoveFinalizer(context.TODO(), racing, cm, "example.com/a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+
+		By("retrying the patch on the concurrently changed finalizers")
+		Expect(racing.raced).To(BeTrue())
+		Expect(fetchFinalizers()).To(Equal([]string{"example.com/other"}))
+	})
+})
+
+// racingFinalizerWriter adds a finalizer to the object before the first
+// patch, as another writer would.
+type racingFinalizerWriter struct {
+	dynamic.NamespaceableResourceInterface
+	finalizer string
+	raced     bool
+}
+
+func (r *racingFinalizerWriter) Namespace(ns string) dynamic.ResourceInterface {
+	return &racingFinalizerResourceWriter{ResourceInterface: r.NamespaceableResourceInterface.Namespace(ns), parent: r}
+}
+
+type racingFinalizerResourceWriter struct {
+	dynamic.ResourceInterface
+	parent *racingFinalizerWriter
+}
+
+func (r *racingFinalizerResourceWriter) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if !r.parent.raced {
+		r.parent.raced = true
+		fetchedUns, err := r.ResourceInterface.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		fetchedUns.SetFinalizers(append(fetchedUns.GetFinalizers(), r.parent.finalizer))
+		if _, err = r.ResourceInterface.Update(ctx, fetchedUns, metav1.UpdateOptions{}); err != nil {
+			return nil, err
+		}
+	}
+	return r.ResourceInterface.Patch(ctx, name, pt, data, options, subresources...)
+}
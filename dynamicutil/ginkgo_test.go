@@ -0,0 +1,19 @@
+package dynamicutil
+
+import "github.com/onsi/ginkgo"
+
+// The specs use the ginkgo DSL through these aliases rather than a dot
+// import, which would conflict with When.
+var (
+	Describe      = ginkgo.Describe
+	Context       = ginkgo.Context
+	It            = ginkgo.It
+	By            = ginkgo.By
+	BeforeEach    = ginkgo.BeforeEach
+	AfterEach     = ginkgo.AfterEach
+	BeforeSuite   = ginkgo.BeforeSuite
+	AfterSuite    = ginkgo.AfterSuite
+	Fail          = ginkgo.Fail
+	GinkgoRecover = ginkgo.GinkgoRecover
+	RunSpecs      = ginkgo.RunSpecs
+)
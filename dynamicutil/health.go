@@ -0,0 +1,315 @@
+package dynamicutil
+
+import (
+	"fmt"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// HealthStatus is the health of an object, as assessed by kstatus
+type HealthStatus string
+
+const (
+	// HealthCurrent means that the object is ready: its controller has
+	// observed its latest spec and fully reconciled it
+	HealthCurrent HealthStatus = "Current"
+	// HealthInProgress means that the object is being reconciled
+	HealthInProgress HealthStatus = "InProgress"
+	// HealthFailed means that the reconciliation of the object failed and
+	// won't make progress without intervention
+	HealthFailed HealthStatus = "Failed"
+	// HealthTerminating means that the object is being deleted
+	HealthTerminating HealthStatus = "Terminating"
+)
+
+// Health is the result of a health check
+type Health struct {
+	Status HealthStatus
+	// Message tells why the object isn't current
+	Message string
+}
+
+// HealthCheckFunc assesses the health of an object
+type HealthCheckFunc func(obj *unstructured.Unstructured) (Health, error)
+
+var (
+	healthChecksMu sync.RWMutex
+	healthChecks   = map[schema.GroupVersionKind]HealthCheckFunc{
+		{Group: "apps", Kind: "Deployment"}:                               deploymentHealth,
+		{Group: "apps", Kind: "StatefulSet"}:                              statefulSetHealth,
+		{Group: "apps", Kind: "DaemonSet"}:                                daemonSetHealth,
+		{Group: "batch", Kind: "Job"}:                                     jobHealth,
+		{Kind: "PersistentVolumeClaim"}:                                   pvcHealth,
+		{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}: crdHealth,
+	}
+)
+
+// RegisterHealthCheck registers the health check of the objects of the given
+// kind, replacing the built-in or registered one. An empty version matches
+// all the versions of the kind.
+func RegisterHealthCheck(gvk schema.GroupVersionKind, check HealthCheckFunc) {
+	healthChecksMu.Lock()
+	defer healthChecksMu.Unlock()
+	healthChecks[gvk] = check
+}
+
+// CheckHealth assesses the health of obj with the health check of its kind.
+// Objects of kinds without a health check are current once the generation
+// of their spec is observed, and their Ready condition, if any, is true.
+func CheckHealth(obj *unstructured.Unstructured) (Health, error) {
+	if obj.GetDeletionTimestamp() != nil {
+		return Health{Status: HealthTerminating, Message: "object is being deleted"}, nil
+	}
+
+	gvk := obj.GroupVersionKind()
+	healthChecksMu.RLock()
+	check, ok := healthChecks[gvk]
+	if !ok {
+		check, ok = healthChecks[schema.GroupVersionKind{Group: gvk.Group, Kind: gvk.Kind}]
+	}
+	healthChecksMu.RUnlock()
+	if !ok {
+		check = genericHealth
+	}
+	return check(obj)
+}
+
+func inProgress(format string, args ...interface{}) (Health, error) {
+	return Health{Status: HealthInProgress, Message: fmt.Sprintf(format, args...)}, nil
+}
+
+func failed(format string, args ...interface{}) (Health, error) {
+	return Health{Status: HealthFailed, Message: fmt.Sprintf(format, args...)}, nil
+}
+
+// observedGeneration tells whether the controller of obj has observed the
+// latest generation of its spec. Objects without status.observedGeneration
+// are considered observed.
+func observedGeneration(obj *unstructured.Unstructured) (bool, error) {
+	observed, found, err := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if err != nil || !found {
+		return true, err
+	}
+	return observed >= obj.GetGeneration(), nil
+}
+
+// nestedInt64 returns the integer field of obj at the path, or def if it's
+// missing.
+func nestedInt64(obj *unstructured.Unstructured, def int64, fields ...string) (int64, error) {
+	value, found, err := unstructured.NestedInt64(obj.Object, fields...)
+	if err != nil || !found {
+		return def, err
+	}
+	return value, nil
+}
+
+func genericHealth(obj *unstructured.Unstructured) (Health, error) {
+	observed, err := observedGeneration(obj)
+	if err != nil {
+		return Health{}, err
+	}
+	if !observed {
+		return inProgress("generation %d is not observed yet", obj.GetGeneration())
+	}
+
+	conditions, err := GetConditions(obj)
+	if err != nil {
+		return Health{}, err
+	}
+	for _, condition := range conditions {
+		switch {
+		case condition.Type == "Stalled" && condition.Status == metav1.ConditionTrue:
+			return failed("stalled: %s", condition.Message)
+		case condition.Type == "Reconciling" && condition.Status == metav1.ConditionTrue:
+			return inProgress("reconciling: %s", condition.Message)
+		case condition.Type == "Ready" && condition.Status != metav1.ConditionTrue:
+			return inProgress("not ready: %s", condition.Message)
+		}
+	}
+	return Health{Status: HealthCurrent}, nil
+}
+
+func deploymentHealth(obj *unstructured.Unstructured) (Health, error) {
+	observed, err := observedGeneration(obj)
+	if err != nil {
+		return Health{}, err
+	}
+	if !observed {
+		return inProgress("generation %d is not observed yet", obj.GetGeneration())
+	}
+
+	progressing, err := GetCondition(obj, "Progressing")
+	if err != nil {
+		return Health{}, err
+	}
+	if progressing != nil && progressing.Reason == "ProgressDeadlineExceeded" {
+		return failed("progress deadline exceeded")
+	}
+
+	replicas, err := nestedInt64(obj, 1, "spec", "replicas")
+	if err != nil {
+		return Health{}, err
+	}
+	updated, err := nestedInt64(obj, 0, "status", "updatedReplicas")
+	if err != nil {
+		return Health{}, err
+	}
+	total, err := nestedInt64(obj, 0, "status", "replicas")
+	if err != nil {
+		return Health{}, err
+	}
+	available, err := nestedInt64(obj, 0, "status", "availableReplicas")
+	if err != nil {
+		return Health{}, err
+	}
+	switch {
+	case updated < replicas:
+		return inProgress("%d of %d replicas updated", updated, replicas)
+	case total > updated:
+		return inProgress("%d old replicas pending termination", total-updated)
+	case available < updated:
+		return inProgress("%d of %d updated replicas available", available, updated)
+	}
+	return Health{Status: HealthCurrent}, nil
+}
+
+func statefulSetHealth(obj *unstructured.Unstructured) (Health, error) {
+	observed, err := observedGeneration(obj)
+	if err != nil {
+		return Health{}, err
+	}
+	if !observed {
+		return inProgress("generation %d is not observed yet", obj.GetGeneration())
+	}
+
+	strategy, _, err := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type")
+	if err != nil {
+		return Health{}, err
+	}
+	if strategy == "OnDelete" {
+		return Health{Status: HealthCurrent}, nil
+	}
+
+	replicas, err := nestedInt64(obj, 1, "spec", "replicas")
+	if err != nil {
+		return Health{}, err
+	}
+	partition, err := nestedInt64(obj, 0, "spec", "updateStrategy", "rollingUpdate", "partition")
+	if err != nil {
+		return Health{}, err
+	}
+	ready, err := nestedInt64(obj, 0, "status", "readyReplicas")
+	if err != nil {
+		return Health{}, err
+	}
+	updated, err := nestedInt64(obj, 0, "status", "updatedReplicas")
+	if err != nil {
+		return Health{}, err
+	}
+	if ready < replicas {
+		return inProgress("%d of %d replicas ready", ready, replicas)
+	}
+	if partition > 0 {
+		if updated < replicas-partition {
+			return inProgress("%d of %d replicas updated", updated, replicas-partition)
+		}
+		return Health{Status: HealthCurrent}, nil
+	}
+
+	currentRevision, _, err := unstructured.NestedString(obj.Object, "status", "currentRevision")
+	if err != nil {
+		return Health{}, err
+	}
+	updateRevision, _, err := unstructured.NestedString(obj.Object, "status", "updateRevision")
+	if err != nil {
+		return Health{}, err
+	}
+	if currentRevision != updateRevision {
+		return inProgress("%d of %d replicas updated", updated, replicas)
+	}
+	return Health{Status: HealthCurrent}, nil
+}
+
+func daemonSetHealth(obj *unstructured.Unstructured) (Health, error) {
+	observed, err := observedGeneration(obj)
+	if err != nil {
+		return Health{}, err
+	}
+	if !observed {
+		return inProgress("generation %d is not observed yet", obj.GetGeneration())
+	}
+
+	desired, err := nestedInt64(obj, 0, "status", "desiredNumberScheduled")
+	if err != nil {
+		return Health{}, err
+	}
+	updated, err := nestedInt64(obj, 0, "status", "updatedNumberScheduled")
+	if err != nil {
+		return Health{}, err
+	}
+	available, err := nestedInt64(obj, 0, "status", "numberAvailable")
+	if err != nil {
+		return Health{}, err
+	}
+	switch {
+	case updated < desired:
+		return inProgress("%d of %d pods updated", updated, desired)
+	case available < desired:
+		return inProgress("%d of %d pods available", available, desired)
+	}
+	return Health{Status: HealthCurrent}, nil
+}
+
+func jobHealth(obj *unstructured.Unstructured) (Health, error) {
+	conditions, err := GetConditions(obj)
+	if err != nil {
+		return Health{}, err
+	}
+	for _, condition := range conditions {
+		if condition.Status != metav1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case "Complete":
+			return Health{Status: HealthCurrent}, nil
+		case "Failed":
+			return failed("job failed: %s", condition.Message)
+		}
+	}
+	return inProgress("job is not complete")
+}
+
+func pvcHealth(obj *unstructured.Unstructured) (Health, error) {
+	phase, _, err := unstructured.NestedString(obj.Object, "status", "phase")
+	if err != nil {
+		return Health{}, err
+	}
+	switch phase {
+	case "Bound":
+		return Health{Status: HealthCurrent}, nil
+	case "Lost":
+		return failed("claim lost its volume")
+	}
+	return inProgress("claim is not bound")
+}
+
+func crdHealth(obj *unstructured.Unstructured) (Health, error) {
+	namesAccepted, err := GetCondition(obj, "NamesAccepted")
+	if err != nil {
+		return Health{}, err
+	}
+	if namesAccepted != nil && namesAccepted.Status == metav1.ConditionFalse {
+		return failed("names not accepted: %s", namesAccepted.Message)
+	}
+	established, err := IsConditionStatus(obj, "Established", metav1.ConditionTrue)
+	if err != nil {
+		return Health{}, err
+	}
+	if !established {
+		return inProgress("definition is not established")
+	}
+	return Health{Status: HealthCurrent}, nil
+}
@@ -0,0 +1,216 @@
+package dynamicutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("Health", func() {
+	newObject := func(apiVersion, kind string, generation int64, fields map[string]interface{}) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: fields}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetName("foo")
+		obj.SetGeneration(generation)
+		return obj
+	}
+	condition := func(conditionType, status, reason string) map[string]interface{} {
+		return map[string]interface{}{"type": conditionType, "status": status, "reason": reason}
+	}
+
+	table.DescribeTable("assesses the health of objects",
+		func(obj *unstructured.Unstructured, expected HealthStatus) {
+			health, err := CheckHealth(obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(health.Status).To(Equal(expected))
+		},
+		table.Entry("Deployment not observed", newObject("apps/v1", "Deployment", 2, map[string]interface{}{
+			"status": map[string]interface{}{"observedGeneration": int64(1)},
+		}), HealthInProgress),
+		table.Entry("Deployment rolling out", newObject("apps/v1", "Deployment", 1, map[string]interface{}{
+			"spec":   map[string]interface{}{"replicas": int64(2)},
+			"status": map[string]interface{}{"observedGeneration": int64(1), "replicas": int64(3), "updatedReplicas": int64(2), "availableReplicas": int64(2)},
+		}), HealthInProgress),
+		table.Entry("Deployment available", newObject("apps/v1", "Deployment", 1, map[string]interface{}{
+			"spec":   map[string]interface{}{"replicas": int64(2)},
+			"status": map[string]interface{}{"observedGeneration": int64(1), "replicas": int64(2), "updatedReplicas": int64(2), "availableReplicas": int64(2)},
+		}), HealthCurrent),
+		table.Entry("Deployment past its progress deadline", newObject("apps/v1", "Deployment", 1, map[string]interface{}{
+			"status": map[string]interface{}{
+				"observedGeneration": int64(1),
+				"conditions":         []interface{}{condition("Progressing", "False", "ProgressDeadlineExceeded")},
+			},
+		}), HealthFailed),
+		table.Entry("StatefulSet updating", newObject("apps/v1", "StatefulSet", 1, map[string]interface{}{
+			"status": map[string]interface{}{"observedGeneration": int64(1), "readyReplicas": int64(1), "currentRevision": "a", "updateRevision": "b"},
+		}), HealthInProgress),
+		table.Entry("StatefulSet updated", newObject("apps/v1", "StatefulSet", 1, map[string]interface{}{
+			"status": map[string]interface{}{"observedGeneration": int64(1), "readyReplicas": int64(1), "currentRevision": "b", "updateRevision": "b"},
+		}), HealthCurrent),
+		table.Entry("StatefulSet updated up to its partition", newObject("apps/v1", "StatefulSet", 1, map[string]interface{}{
+			"spec": map[string]interface{}{
+				"replicas":       int64(3),
+				"updateStrategy": map[string]interface{}{"rollingUpdate": map[string]interface{}{"partition": int64(2)}},
+			},
+			"status": map[string]interface{}{"observedGeneration": int64(1), "readyReplicas": int64(3), "updatedReplicas": int64(1)},
+		}), HealthCurrent),
+		table.Entry("DaemonSet unavailable", newObject("apps/v1", "DaemonSet", 1, map[string]interface{}{
+			"status": map[string]interface{}{"observedGeneration": int64(1), "desiredNumberScheduled": int64(2), "updatedNumberScheduled": int64(2), "numberAvailable": int64(1)},
+		}), HealthInProgress),
+		table.Entry("DaemonSet available", newObject("apps/v1", "DaemonSet", 1, map[string]interface{}{
+			"status": map[string]interface{}{"observedGeneration": int64(1), "desiredNumberScheduled": int64(2), "updatedNumberScheduled": int64(2), "numberAvailable": int64(2)},
+		}), HealthCurrent),
+		table.Entry("Job running", newObject("batch/v1", "Job", 1, map[string]interface{}{}), HealthInProgress),
+		table.Entry("Job complete", newObject("batch/v1", "Job", 1, map[string]interface{}{
+			"status": map[string]interface{}{"conditions": []interface{}{condition("Complete", "True", "")}},
+		}), HealthCurrent),
+		table.Entry("Job failed", newObject("batch/v1", "Job", 1, map[string]interface{}{
+			"status": map[string]interface{}{"conditions": []interface{}{condition("Failed", "True", "BackoffLimitExceeded")}},
+		}), HealthFailed),
+		table.Entry("PersistentVolumeClaim pending", newObject("v1", "PersistentVolumeClaim", 0, map[string]interface{}{
+			"status": map[string]interface{}{"phase": "Pending"},
+		}), HealthInProgress),
+		table.Entry("PersistentVolumeClaim bound", newObject("v1", "PersistentVolumeClaim", 0, map[string]interface{}{
+			"status": map[string]interface{}{"phase": "Bound"},
+		}), HealthCurrent),
+		table.Entry("CustomResourceDefinition established", newObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", 1, map[string]interface{}{
+			"status": map[string]interface{}{"conditions": []interface{}{condition("NamesAccepted", "True", ""), condition("Established", "True", "")}},
+		}), HealthCurrent),
+		table.Entry("CustomResourceDefinition with conflicting names", newObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", 1, map[string]interface{}{
+			"status": map[string]interface{}{"conditions": []interface{}{condition("NamesAccepted", "False", "")}},
+		}), HealthFailed),
+		table.Entry("custom resource not ready", newObject("example.com/v1", "Widget", 1, map[string]interface{}{
+			"status": map[string]interface{}{"observedGeneration": int64(1), "conditions": []interface{}{condition("Ready", "False", "")}},
+		}), HealthInProgress),
+		table.Entry("custom resource ready", newObject("example.com/v1", "Widget", 1, map[string]interface{}{
+			"status": map[string]interface{}{"observedGeneration": int64(1), "conditions": []interface{}{condition("Ready", "True", "")}},
+		}), HealthCurrent),
+		table.Entry("custom resource stalled", newObject("example.com/v1", "Widget", 1, map[string]interface{}{
+			"status": map[string]interface{}{"conditions": []interface{}{condition("Stalled", "True", "")}},
+		}), HealthFailed),
+		table.Entry("object without status", newObject("v1", "ConfigMap", 0, map[string]interface{}{}), HealthCurrent),
+	)
+
+	It("assesses objects being deleted as terminating", func() {
+		obj := newObject("v1", "ConfigMap", 0, map[string]interface{}{})
+		now := metav1.Now()
+		obj.SetDeletionTimestamp(&now)
+
+		health, err := CheckHealth(obj)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(health.Status).To(Equal(HealthTerminating))
+	})
+
+	It("uses the registered health checks", func() {
+		gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Gadget"}
+		RegisterHealthCheck(gvk, func(obj *unstructured.Unstructured) (Health, error) {
+			return Health{Status: HealthFailed, Message: "broken"}, nil
+		})
+
+		health, err := CheckHealth(newObject("example.com/v1", "Gadget", 1, map[string]interface{}{}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(health).To(Equal(Health{Status: HealthFailed, Message: "broken"}))
+
+		By("matching the version")
+		health, err = CheckHealth(newObject("example.com/v2", "Gadget", 1, map[string]interface{}{}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(health.Status).To(Equal(HealthCurrent))
+	})
+
+	Describe("WaitReady", func() {
+		var c *Client
+		var pvc *corev1.PersistentVolumeClaim
+
+		BeforeEach(func() {
+			mapper := meta.NewDefaultRESTMapper(nil)
+			mapper.Add(corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"), meta.RESTScopeNamespace)
+			mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+			c = NewClient(dynClient, mapper, clientgoscheme.Scheme)
+
+			pvc = &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("pvc-%d", rand.Int31()),
+					Namespace: "default",
+				},
+			}
+		})
+
+		createPVC := func() {
+			_, err := c.CreateOrUpdate(context.TODO(), pvc, func() error {
+				pvc.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+				pvc.Spec.Resources.Requests = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")}
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		setPhase := func(phase corev1.PersistentVolumeClaimPhase) {
+			ri, err := c.Resource(pvc)
+			Expect(err).NotTo(HaveOccurred())
+			patch := []byte(fmt.Sprintf(`{"status":{"phase":%q}}`, phase))
+			_, err = ri.Namespace(pvc.Namespace).Patch(context.TODO(), pvc.Name, types.MergePatchType, patch, metav1.PatchOptions{}, "status")
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		It("returns once the objects are ready", func() {
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("cm-%d", rand.Int31()), Namespace: "default"}}
+			_, err := c.CreateOrUpdate(context.TODO(), cm, configMapSetter(cm, "foo", "bar"))
+			Expect(err).NotTo(HaveOccurred())
+			createPVC()
+
+			done := make(chan error, 1)
+			go func() {
+				defer GinkgoRecover()
+				done <- WaitReady(context.TODO(), c, cm, pvc)
+			}()
+			Consistently(done, time.Second).ShouldNot(Receive())
+
+			setPhase(corev1.ClaimBound)
+			Eventually(done, 10*time.Second).Should(Receive(BeNil()))
+		})
+
+		It("waits for objects to be created", func() {
+			done := make(chan error, 1)
+			go func() {
+				defer GinkgoRecover()
+				done <- WaitReady(context.TODO(), c, pvc.DeepCopy())
+			}()
+			Consistently(done, time.Second).ShouldNot(Receive())
+
+			createPVC()
+			setPhase(corev1.ClaimBound)
+			Eventually(done, 10*time.Second).Should(Receive(BeNil()))
+		})
+
+		It("fails when an object has failed", func() {
+			createPVC()
+			setPhase(corev1.ClaimLost)
+
+			err := WaitReady(context.TODO(), c, pvc)
+			Expect(err).To(MatchError(ContainSubstring("claim lost its volume")))
+		})
+
+		It("tells why the objects aren't ready when the context is done", func() {
+			createPVC()
+
+			ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+			defer cancel()
+			err := WaitReady(ctx, c, pvc)
+			Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("claim is not bound")))
+		})
+	})
+})
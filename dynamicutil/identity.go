@@ -0,0 +1,197 @@
+package dynamicutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ObjIdentity identifies an object across kinds by its group, kind,
+// namespace and name. The version of the kind isn't part of it, an object
+// has the same identity in all the versions of its kind.
+type ObjIdentity struct {
+	GroupKind schema.GroupKind
+	Namespace string
+	Name      string
+}
+
+// ObjIdentityFromObject returns the identity of obj, the kind of typed
+// objects is looked up in the scheme, see NewClient.
+func ObjIdentityFromObject(scheme *runtime.Scheme, obj Object) (ObjIdentity, error) {
+	gvk, err := gvkForObject(scheme, obj)
+	if err != nil {
+		return ObjIdentity{}, err
+	}
+	return ObjIdentity{GroupKind: gvk.GroupKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()}, nil
+}
+
+// ObjIdentityFromUnstructured returns the identity of obj
+func ObjIdentityFromUnstructured(obj *unstructured.Unstructured) ObjIdentity {
+	return ObjIdentity{
+		GroupKind: obj.GroupVersionKind().GroupKind(),
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+}
+
+// ParseObjIdentity parses the identity formatted by ObjIdentity.String
+func ParseObjIdentity(s string) (ObjIdentity, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 4 {
+		return ObjIdentity{}, fmt.Errorf("invalid object identity %q, expected group/kind/namespace/name", s)
+	}
+	id := ObjIdentity{
+		GroupKind: schema.GroupKind{Group: parts[0], Kind: parts[1]},
+		Namespace: parts[2],
+		Name:      parts[3],
+	}
+	if id.GroupKind.Kind == "" || id.Name == "" {
+		return ObjIdentity{}, fmt.Errorf("invalid object identity %q, kind and name are required", s)
+	}
+	return id, nil
+}
+
+// String formats the identity as group/kind/namespace/name, e.g.
+// apps/Deployment/default/foo. The group of the core kinds and the namespace
+// of cluster-scoped objects are empty, e.g. /Namespace//foo.
+func (id ObjIdentity) String() string {
+	return id.GroupKind.Group + "/" + id.GroupKind.Kind + "/" + id.Namespace + "/" + id.Name
+}
+
+// Less orders identities by group, kind, namespace and name
+func (id ObjIdentity) Less(other ObjIdentity) bool {
+	if id.GroupKind.Group != other.GroupKind.Group {
+		return id.GroupKind.Group < other.GroupKind.Group
+	}
+	if id.GroupKind.Kind != other.GroupKind.Kind {
+		return id.GroupKind.Kind < other.GroupKind.Kind
+	}
+	if id.Namespace != other.Namespace {
+		return id.Namespace < other.Namespace
+	}
+	return id.Name < other.Name
+}
+
+// ObjIdentitySet is a set of object identities
+type ObjIdentitySet map[ObjIdentity]struct{}
+
+// NewObjIdentitySet creates a set of the given identities
+func NewObjIdentitySet(ids ...ObjIdentity) ObjIdentitySet {
+	s := make(ObjIdentitySet, len(ids))
+	s.Insert(ids...)
+	return s
+}
+
+// Insert adds the identities to the set
+func (s ObjIdentitySet) Insert(ids ...ObjIdentity) ObjIdentitySet {
+	for _, id := range ids {
+		s[id] = struct{}{}
+	}
+	return s
+}
+
+// Delete removes the identities from the set
+func (s ObjIdentitySet) Delete(ids ...ObjIdentity) ObjIdentitySet {
+	for _, id := range ids {
+		delete(s, id)
+	}
+	return s
+}
+
+// Has tells whether the identity is in the set
+func (s ObjIdentitySet) Has(id ObjIdentity) bool {
+	_, ok := s[id]
+	return ok
+}
+
+// Len returns the size of the set
+func (s ObjIdentitySet) Len() int {
+	return len(s)
+}
+
+// Union returns a new set with the identities of both sets
+func (s ObjIdentitySet) Union(other ObjIdentitySet) ObjIdentitySet {
+	result := make(ObjIdentitySet, len(s)+len(other))
+	for id := range s {
+		result[id] = struct{}{}
+	}
+	for id := range other {
+		result[id] = struct{}{}
+	}
+	return result
+}
+
+// Difference returns a new set with the identities of s which aren't in
+// other
+func (s ObjIdentitySet) Difference(other ObjIdentitySet) ObjIdentitySet {
+	result := make(ObjIdentitySet)
+	for id := range s {
+		if !other.Has(id) {
+			result[id] = struct{}{}
+		}
+	}
+	return result
+}
+
+// Intersection returns a new set with the identities in both sets
+func (s ObjIdentitySet) Intersection(other ObjIdentitySet) ObjIdentitySet {
+	result := make(ObjIdentitySet)
+	for id := range s {
+		if other.Has(id) {
+			result[id] = struct{}{}
+		}
+	}
+	return result
+}
+
+// List returns the identities of the set, sorted
+func (s ObjIdentitySet) List() []ObjIdentity {
+	ids := make([]ObjIdentity, 0, len(s))
+	for id := range s {
+		ids = append(ids, id)
+	}
+	sortObjIdentities(ids)
+	return ids
+}
+
+// ObjMap is a map of unstructured objects keyed by their identity
+type ObjMap map[ObjIdentity]*unstructured.Unstructured
+
+// NewObjMap creates a map of the objects, an object replaces the previous
+// object with the same identity.
+func NewObjMap(objects ...*unstructured.Unstructured) ObjMap {
+	m := make(ObjMap, len(objects))
+	for _, obj := range objects {
+		m[ObjIdentityFromUnstructured(obj)] = obj
+	}
+	return m
+}
+
+// Identities returns the set of the identities of the map
+func (m ObjMap) Identities() ObjIdentitySet {
+	s := make(ObjIdentitySet, len(m))
+	for id := range m {
+		s[id] = struct{}{}
+	}
+	return s
+}
+
+// Objects returns the objects of the map, sorted by identity
+func (m ObjMap) Objects() []*unstructured.Unstructured {
+	ids := m.Identities().List()
+	objects := make([]*unstructured.Unstructured, 0, len(ids))
+	for _, id := range ids {
+		objects = append(objects, m[id])
+	}
+	return objects
+}
+
+func sortObjIdentities(ids []ObjIdentity) {
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Less(ids[j])
+	})
+}
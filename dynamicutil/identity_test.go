@@ -0,0 +1,101 @@
+package dynamicutil
+
+import (
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("ObjIdentity", func() {
+	deployment := ObjIdentity{GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"}, Namespace: "default", Name: "foo"}
+	configMap := ObjIdentity{GroupKind: schema.GroupKind{Kind: "ConfigMap"}, Namespace: "default", Name: "foo"}
+	namespace := ObjIdentity{GroupKind: schema.GroupKind{Kind: "Namespace"}, Name: "foo"}
+
+	table.DescribeTable("formats and parses identities",
+		func(id ObjIdentity, s string) {
+			Expect(id.String()).To(Equal(s))
+			parsed, err := ParseObjIdentity(s)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed).To(Equal(id))
+		},
+		table.Entry("namespaced", deployment, "apps/Deployment/default/foo"),
+		table.Entry("core group", configMap, "/ConfigMap/default/foo"),
+		table.Entry("cluster-scoped", namespace, "/Namespace//foo"),
+	)
+
+	table.DescribeTable("rejects invalid identities",
+		func(s string) {
+			_, err := ParseObjIdentity(s)
+			Expect(err).To(HaveOccurred())
+		},
+		table.Entry("too few parts", "Deployment/default/foo"),
+		table.Entry("too many parts", "apps/Deployment/default/foo/bar"),
+		table.Entry("no kind", "apps//default/foo"),
+		table.Entry("no name", "apps/Deployment/default/"),
+	)
+
+	It("returns the identity of objects", func() {
+		deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"}}
+		id, err := ObjIdentityFromObject(clientgoscheme.Scheme, deploy)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal(deployment))
+
+		_, err = ObjIdentityFromObject(runtime.NewScheme(), deploy)
+		Expect(err).To(HaveOccurred())
+
+		uns := &unstructured.Unstructured{}
+		uns.SetAPIVersion("apps/v1beta2")
+		uns.SetKind("Deployment")
+		uns.SetNamespace("default")
+		uns.SetName("foo")
+		Expect(ObjIdentityFromUnstructured(uns)).To(Equal(deployment))
+	})
+
+	Describe("ObjIdentitySet", func() {
+		It("sorts the identities", func() {
+			s := NewObjIdentitySet(deployment, namespace, configMap)
+			Expect(s.List()).To(Equal([]ObjIdentity{configMap, namespace, deployment}))
+		})
+
+		It("computes unions, differences and intersections", func() {
+			a := NewObjIdentitySet(deployment, configMap)
+			b := NewObjIdentitySet(configMap, namespace)
+
+			Expect(a.Union(b).List()).To(Equal([]ObjIdentity{configMap, namespace, deployment}))
+			Expect(a.Difference(b).List()).To(Equal([]ObjIdentity{deployment}))
+			Expect(b.Difference(a).List()).To(Equal([]ObjIdentity{namespace}))
+			Expect(a.Intersection(b).List()).To(Equal([]ObjIdentity{configMap}))
+
+			By("leaving the sets unchanged")
+			Expect(a.Len()).To(Equal(2))
+			Expect(b.Len()).To(Equal(2))
+
+			a.Delete(configMap).Insert(namespace)
+			Expect(a.Has(configMap)).To(BeFalse())
+			Expect(a.Has(namespace)).To(BeTrue())
+		})
+	})
+
+	It("maps objects by identity", func() {
+		newObject := func(apiVersion, kind, ns, name string) *unstructured.Unstructured {
+			obj := &unstructured.Unstructured{}
+			obj.SetAPIVersion(apiVersion)
+			obj.SetKind(kind)
+			obj.SetNamespace(ns)
+			obj.SetName(name)
+			return obj
+		}
+		deploy := newObject("apps/v1", "Deployment", "default", "foo")
+		cm := newObject("v1", "ConfigMap", "default", "foo")
+
+		m := NewObjMap(deploy, cm)
+		Expect(m).To(HaveKeyWithValue(deployment, deploy))
+		Expect(m.Identities().List()).To(Equal([]ObjIdentity{configMap, deployment}))
+		Expect(m.Objects()).To(Equal([]*unstructured.Unstructured{cm, deploy}))
+	})
+})
@@ -0,0 +1,421 @@
+package dynamicutil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// IgnoreDifference is a rule excluding fields owned by other actors, like
+// spec.replicas of a Deployment scaled by an HPA, from CreateOrUpdate and
+// CreateOrPatch. The ignored fields don't trigger an update, and their live
+// values are kept by the update.
+//
+// Ignored array elements, like the containers injected by a webhook, are
+// kept by identity: the elements matched in the object are dropped, and the
+// elements matched in the live object are inserted at their live positions.
+// Pointers to fields inside array elements are index based: the field of the
+// element at the same index in the live object is kept.
+type IgnoreDifference struct {
+	// Group of the objects the rule applies to, empty for the core group
+	Group string
+	// Kind of the objects the rule applies to, empty for all kinds
+	Kind string
+	// JSONPointers are RFC 6901 pointers to the ignored fields, e.g.
+	// /spec/replicas
+	JSONPointers []string
+	// JSONPaths are JSONPath expressions of the ignored fields, e.g.
+	// .spec.template.spec.containers[?(@.name=="istio-proxy")]. Fields, array
+	// indices and slices, wildcards, and filters with the exists, == and !=
+	// operators are supported.
+	JSONPaths []string
+}
+
+// WithIgnoreDifferences adds rules excluding fields from the comparison with
+// the existing object and from the update sent to the API server, see
+// IgnoreDifference.
+func WithIgnoreDifferences(rules ...IgnoreDifference) Option {
+	return func(o *Options) {
+		o.IgnoreDifferences = append(o.IgnoreDifferences, rules...)
+	}
+}
+
+func (r *IgnoreDifference) matches(gk schema.GroupKind) bool {
+	return r.Group == gk.Group && (r.Kind == "" || r.Kind == gk.Kind)
+}
+
+// pointers returns the pointers of the fields ignored by the rule in doc
+func (r *IgnoreDifference) pointers(doc map[string]interface{}) ([]string, error) {
+	pointers := append([]string(nil), r.JSONPointers...)
+	for _, path := range r.JSONPaths {
+		matched, err := jsonPathPointers(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		pointers = append(pointers, matched...)
+	}
+	return pointers, nil
+}
+
+// ignoring returns a copy of obj with the ignored fields set to their values
+// in existing, or removed when they're missing from existing. obj itself is
+// returned when no rule applies to its kind.
+func (o *Options) ignoring(existing, obj runtime.Object) (runtime.Object, error) {
+	gk := existing.GetObjectKind().GroupVersionKind().GroupKind()
+	var rules []*IgnoreDifference
+	for i := range o.IgnoreDifferences {
+		if o.IgnoreDifferences[i].matches(gk) {
+			rules = append(rules, &o.IgnoreDifferences[i])
+		}
+	}
+	if len(rules) == 0 {
+		return obj, nil
+	}
+
+	live, err := unstructuredFromObject(existing)
+	if err != nil {
+		return nil, err
+	}
+	desired, err := unstructuredFromObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	desired = desired.DeepCopy()
+
+	// Whole array elements, like injected containers, are kept by identity:
+	// the desired ones are dropped and the live ones inserted at their live
+	// positions. The other fields are kept by pointer.
+	elements := map[string]*ignoredElements{}
+	seen := map[string]bool{}
+	var pointers []string
+	for _, rule := range rules {
+		for _, doc := range []*unstructured.Unstructured{live, desired} {
+			matched, err := rule.pointers(doc.Object)
+			if err != nil {
+				return nil, err
+			}
+			for _, pointer := range matched {
+				if parent, index, ok := arrayElement(doc.Object, pointer); ok {
+					e := elements[parent]
+					if e == nil {
+						e = &ignoredElements{live: map[int]bool{}, desired: map[int]bool{}}
+						elements[parent] = e
+					}
+					if doc == live {
+						e.live[index] = true
+					} else {
+						e.desired[index] = true
+					}
+					continue
+				}
+				if !seen[pointer] {
+					seen[pointer] = true
+					pointers = append(pointers, pointer)
+				}
+			}
+		}
+	}
+
+	// Values are set in document order so array elements can be appended,
+	// then removed in reverse order so indices are still valid.
+	sortPointers(pointers)
+	var removed []string
+	for _, pointer := range pointers {
+		value, ok := valueAtPointer(live.Object, pointer)
+		if !ok {
+			removed = append(removed, pointer)
+			continue
+		}
+		if err = setAtPointer(desired.Object, pointer, runtime.DeepCopyJSONValue(value)); err != nil {
+			return nil, fmt.Errorf("cannot keep the live value of %s: %w", pointer, err)
+		}
+	}
+	for i := len(removed) - 1; i >= 0; i-- {
+		removeAtPointer(desired.Object, removed[i])
+	}
+
+	// Nested arrays are spliced first, so the pointers of the arrays
+	// containing them are still valid.
+	parents := make([]string, 0, len(elements))
+	for parent := range elements {
+		parents = append(parents, parent)
+	}
+	sortPointers(parents)
+	for i := len(parents) - 1; i >= 0; i-- {
+		if err = elements[parents[i]].splice(live.Object, desired.Object, parents[i]); err != nil {
+			return nil, fmt.Errorf("cannot keep the live elements of %s: %w", parents[i], err)
+		}
+	}
+	return desired, nil
+}
+
+// ignoredElements are the indices of the ignored elements of an array in
+// the live and desired objects.
+type ignoredElements struct {
+	live    map[int]bool
+	desired map[int]bool
+}
+
+// splice replaces the ignored elements of the array at parent in desired by
+// the ignored elements of the live array, at their live positions. The other
+// desired elements are kept in order.
+func (e *ignoredElements) splice(live, desired map[string]interface{}, parent string) error {
+	liveValue, _ := valueAtPointer(live, parent)
+	liveArr, _ := liveValue.([]interface{})
+	desiredValue, _ := valueAtPointer(desired, parent)
+	desiredArr, _ := desiredValue.([]interface{})
+
+	result := make([]interface{}, 0, len(desiredArr)+len(e.live))
+	for i, value := range desiredArr {
+		if !e.desired[i] {
+			result = append(result, value)
+		}
+	}
+	for i, value := range liveArr {
+		if !e.live[i] {
+			continue
+		}
+		pos := i
+		if pos > len(result) {
+			pos = len(result)
+		}
+		result = append(result, nil)
+		copy(result[pos+1:], result[pos:])
+		result[pos] = runtime.DeepCopyJSONValue(value)
+	}
+
+	if desiredArr == nil && len(result) == 0 {
+		return nil
+	}
+	return setAtPointer(desired, parent, result)
+}
+
+// arrayElement returns the pointer of the array and the index of the
+// element, if pointer is an element of an array in doc.
+func arrayElement(doc map[string]interface{}, pointer string) (string, int, bool) {
+	sep := strings.LastIndex(pointer, "/")
+	if sep < 0 {
+		return "", 0, false
+	}
+	parent := pointer[:sep]
+	value, ok := valueAtPointer(doc, parent)
+	if !ok {
+		return "", 0, false
+	}
+	arr, ok := value.([]interface{})
+	if !ok {
+		return "", 0, false
+	}
+	index, err := strconv.Atoi(pointer[sep+1:])
+	if err != nil || index < 0 || index >= len(arr) {
+		return "", 0, false
+	}
+	return parent, index, true
+}
+
+// jsonPathPointers returns the JSON pointers of the values matched by the
+// JSONPath expression in doc.
+func jsonPathPointers(doc map[string]interface{}, expr string) ([]string, error) {
+	if !strings.HasPrefix(expr, "{") {
+		expr = "{" + expr + "}"
+	}
+	parser, err := jsonpath.Parse("ignore", expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath %s: %w", expr, err)
+	}
+
+	matches, err := evalJSONPath([]jsonPathMatch{{value: doc}}, parser.Root)
+	if err != nil {
+		return nil, fmt.Errorf("cannot evaluate JSONPath %s: %w", expr, err)
+	}
+	pointers := make([]string, 0, len(matches))
+	for _, m := range matches {
+		pointers = append(pointers, m.pointer)
+	}
+	return pointers, nil
+}
+
+type jsonPathMatch struct {
+	pointer string
+	value   interface{}
+}
+
+func evalJSONPath(matches []jsonPathMatch, node jsonpath.Node) ([]jsonPathMatch, error) {
+	switch node := node.(type) {
+	case *jsonpath.ListNode:
+		var err error
+		for _, n := range node.Nodes {
+			if matches, err = evalJSONPath(matches, n); err != nil {
+				return nil, err
+			}
+		}
+		return matches, nil
+	case *jsonpath.FieldNode:
+		var result []jsonPathMatch
+		for _, m := range matches {
+			if obj, ok := m.value.(map[string]interface{}); ok {
+				if value, ok := obj[node.Value]; ok {
+					result = append(result, jsonPathMatch{pointer: appendPointer(m.pointer, node.Value), value: value})
+				}
+			}
+		}
+		return result, nil
+	case *jsonpath.WildcardNode:
+		var result []jsonPathMatch
+		for _, m := range matches {
+			result = append(result, children(m)...)
+		}
+		return result, nil
+	case *jsonpath.ArrayNode:
+		var result []jsonPathMatch
+		for _, m := range matches {
+			if arr, ok := m.value.([]interface{}); ok {
+				start, end, step := arrayRange(node.Params, len(arr))
+				for i := start; i < end; i += step {
+					result = append(result, jsonPathMatch{pointer: appendPointer(m.pointer, fmt.Sprint(i)), value: arr[i]})
+				}
+			}
+		}
+		return result, nil
+	case *jsonpath.FilterNode:
+		var result []jsonPathMatch
+		for _, m := range matches {
+			if _, ok := m.value.([]interface{}); !ok {
+				continue
+			}
+			for _, elem := range children(m) {
+				pass, err := evalFilter(elem, node)
+				if err != nil {
+					return nil, err
+				}
+				if pass {
+					result = append(result, elem)
+				}
+			}
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unsupported JSONPath node %s", node.Type())
+	}
+}
+
+// children returns the values of an object, sorted by key, or the elements
+// of an array.
+func children(m jsonPathMatch) []jsonPathMatch {
+	var result []jsonPathMatch
+	switch v := m.value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			result = append(result, jsonPathMatch{pointer: appendPointer(m.pointer, k), value: v[k]})
+		}
+	case []interface{}:
+		for i, value := range v {
+			result = append(result, jsonPathMatch{pointer: appendPointer(m.pointer, fmt.Sprint(i)), value: value})
+		}
+	}
+	return result
+}
+
+// arrayRange returns the indices selected by a [start:end:step] array node,
+// clamped to the bounds of the array.
+func arrayRange(params [3]jsonpath.ParamsEntry, length int) (start, end, step int) {
+	start, end, step = 0, length, 1
+	if params[0].Known {
+		start = params[0].Value
+		if start < 0 {
+			start += length
+		}
+	}
+	if params[1].Known {
+		end = params[1].Value
+		if end < 0 || (end == 0 && params[1].Derived) {
+			end += length
+		}
+	}
+	if params[2].Known && params[2].Value > 0 {
+		step = params[2].Value
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > length {
+		end = length
+	}
+	return start, end, step
+}
+
+func evalFilter(elem jsonPathMatch, node *jsonpath.FilterNode) (bool, error) {
+	lefts, err := evalJSONPath([]jsonPathMatch{elem}, node.Left)
+	if err != nil {
+		return false, err
+	}
+	if node.Operator == "exists" {
+		return len(lefts) > 0, nil
+	}
+	if len(lefts) != 1 {
+		return false, nil
+	}
+
+	right, err := filterOperand(elem, node.Right)
+	if err != nil {
+		return false, err
+	}
+	equal := reflect.DeepEqual(normalizeNumber(lefts[0].value), normalizeNumber(right))
+	switch node.Operator {
+	case "==":
+		return equal, nil
+	case "!=":
+		return !equal, nil
+	default:
+		return false, fmt.Errorf("unsupported JSONPath filter operator %s", node.Operator)
+	}
+}
+
+// filterOperand returns the literal, or the value of the path relative to
+// elem, on the right side of a filter.
+func filterOperand(elem jsonPathMatch, list *jsonpath.ListNode) (interface{}, error) {
+	if len(list.Nodes) == 1 {
+		switch n := list.Nodes[0].(type) {
+		case *jsonpath.TextNode:
+			return n.Text, nil
+		case *jsonpath.IntNode:
+			return n.Value, nil
+		case *jsonpath.FloatNode:
+			return n.Value, nil
+		case *jsonpath.BoolNode:
+			return n.Value, nil
+		}
+	}
+
+	rights, err := evalJSONPath([]jsonPathMatch{elem}, list)
+	if err != nil || len(rights) != 1 {
+		return nil, err
+	}
+	return rights[0].value, nil
+}
+
+// normalizeNumber converts numbers to float64 so that values decoded from
+// JSON compare equal to literals.
+func normalizeNumber(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	return v
+}
@@ -0,0 +1,235 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+var _ = Describe("IgnoreDifferences", func() {
+	var deploymentCli dynamic.NamespaceableResourceInterface
+	var deployUns *unstructured.Unstructured
+	var deplSpec appsv1.DeploymentSpec
+	var replicas int32 = 1
+
+	replicasRule := IgnoreDifference{Group: "apps", Kind: "Deployment", JSONPointers: []string{"/spec/replicas"}}
+	sidecarRule := IgnoreDifference{Group: "apps", Kind: "Deployment", JSONPaths: []string{
+		`.spec.template.spec.containers[?(@.name=="sidecar")]`,
+	}}
+
+	// updateLive changes the live deployment like another actor would
+	updateLive := func(f func(live *appsv1.Deployment)) {
+		liveUns, err := deploymentCli.Namespace(deployUns.GetNamespace()).Get(context.TODO(), deployUns.GetName(), metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		live := &appsv1.Deployment{}
+		Expect(unstructuredConverter.FromUnstructured(liveUns.Object, live)).To(Succeed())
+		f(live)
+		liveUns.Object, err = unstructuredConverter.ToUnstructured(live)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = deploymentCli.Namespace(deployUns.GetNamespace()).Update(context.TODO(), liveUns, metav1.UpdateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	getLive := func() *appsv1.Deployment {
+		liveUns, err := deploymentCli.Namespace(deployUns.GetNamespace()).Get(context.TODO(), deployUns.GetName(), metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		live := &appsv1.Deployment{}
+		Expect(unstructuredConverter.FromUnstructured(liveUns.Object, live)).To(Succeed())
+		return live
+	}
+
+	BeforeEach(func() {
+		deploymentCli = dynClient.Resource(deploymentGVR)
+
+		deployUns = &unstructured.Unstructured{}
+		deployUns.SetName(fmt.Sprintf("deploy-%d", rand.Int31()))
+		deployUns.SetNamespace("default")
+		deployUns.SetGroupVersionKind(deploymentGVK)
+
+		deplSpec = appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"foo": "bar"},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"foo": "bar"},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "busybox",
+							Image: "busybox",
+						},
+					},
+				},
+			},
+		}
+
+		op, err := CreateOrUpdate(context.TODO(), deploymentCli, deployUns, deploymentSpecr(deployUns, deplSpec))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultCreated))
+	})
+
+	It("doesn't update when only ignored fields differ", func() {
+		updateLive(func(live *appsv1.Deployment) {
+			scale := int32(3)
+			live.Spec.Replicas = &scale
+		})
+
+		op, err := CreateOrUpdate(context.TODO(), deploymentCli, deployUns, deploymentSpecr(deployUns, deplSpec), WithIgnoreDifferences(replicasRule))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+	})
+
+	It("keeps the live value of ignored fields on update", func() {
+		updateLive(func(live *appsv1.Deployment) {
+			scale := int32(3)
+			live.Spec.Replicas = &scale
+		})
+
+		deplSpec.Template.Spec.Containers[0].Image = "busybox:latest"
+		op, err := CreateOrUpdate(context.TODO(), deploymentCli, deployUns, deploymentSpecr(deployUns, deplSpec), WithIgnoreDifferences(replicasRule))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+
+		live := getLive()
+		Expect(*live.Spec.Replicas).To(BeEquivalentTo(3))
+		Expect(live.Spec.Template.Spec.Containers[0].Image).To(Equal("busybox:latest"))
+	})
+
+	It("keeps the live value of ignored fields on patch", func() {
+		updateLive(func(live *appsv1.Deployment) {
+			scale := int32(3)
+			live.Spec.Replicas = &scale
+		})
+
+		deplSpec.Template.Spec.Containers[0].Image = "busybox:latest"
+		op, err := CreateOrPatch(context.TODO(), deploymentCli, deployUns, deploymentSpecr(deployUns, deplSpec), WithIgnoreDifferences(replicasRule))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+
+		live := getLive()
+		Expect(*live.Spec.Replicas).To(BeEquivalentTo(3))
+		Expect(live.Spec.Template.Spec.Containers[0].Image).To(Equal("busybox:latest"))
+	})
+
+	It("keeps injected containers matched by JSONPath", func() {
+		updateLive(func(live *appsv1.Deployment) {
+			live.Spec.Template.Spec.Containers = append(live.Spec.Template.Spec.Containers, corev1.Container{
+				Name:  "sidecar",
+				Image: "sidecar",
+			})
+		})
+
+		op, err := CreateOrUpdate(context.TODO(), deploymentCli, deployUns, deploymentSpecr(deployUns, deplSpec), WithIgnoreDifferences(sidecarRule))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+
+		deplSpec.Template.Spec.Containers[0].Image = "busybox:latest"
+		op, err = CreateOrUpdate(context.TODO(), deploymentCli, deployUns, deploymentSpecr(deployUns, deplSpec), WithIgnoreDifferences(sidecarRule))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+
+		live := getLive()
+		Expect(live.Spec.Template.Spec.Containers).To(HaveLen(2))
+		Expect(live.Spec.Template.Spec.Containers[1].Name).To(Equal("sidecar"))
+	})
+
+	It("keeps injected containers at their live positions", func() {
+		updateLive(func(live *appsv1.Deployment) {
+			live.Spec.Template.Spec.Containers = append([]corev1.Container{{Name: "sidecar", Image: "sidecar"}}, live.Spec.Template.Spec.Containers...)
+		})
+
+		op, err := CreateOrUpdate(context.TODO(), deploymentCli, deployUns, deploymentSpecr(deployUns, deplSpec), WithIgnoreDifferences(sidecarRule))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+
+		deplSpec.Template.Spec.Containers[0].Image = "busybox:latest"
+		op, err = CreateOrUpdate(context.TODO(), deploymentCli, deployUns, deploymentSpecr(deployUns, deplSpec), WithIgnoreDifferences(sidecarRule))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+
+		live := getLive()
+		Expect(live.Spec.Template.Spec.Containers).To(HaveLen(2))
+		Expect(live.Spec.Template.Spec.Containers[0].Name).To(Equal("sidecar"))
+		Expect(live.Spec.Template.Spec.Containers[1].Name).To(Equal("busybox"))
+		Expect(live.Spec.Template.Spec.Containers[1].Image).To(Equal("busybox:latest"))
+	})
+
+	It("removes the injected containers which aren't ignored", func() {
+		updateLive(func(live *appsv1.Deployment) {
+			live.Spec.Template.Spec.Containers = append(live.Spec.Template.Spec.Containers,
+				corev1.Container{Name: "istio-proxy", Image: "istio-proxy"},
+				corev1.Container{Name: "sidecar", Image: "sidecar"},
+			)
+		})
+
+		op, err := CreateOrUpdate(context.TODO(), deploymentCli, deployUns, deploymentSpecr(deployUns, deplSpec), WithIgnoreDifferences(sidecarRule))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+
+		live := getLive()
+		Expect(live.Spec.Template.Spec.Containers).To(HaveLen(2))
+		Expect(live.Spec.Template.Spec.Containers[0].Name).To(Equal("busybox"))
+		Expect(live.Spec.Template.Spec.Containers[1].Name).To(Equal("sidecar"))
+	})
+
+	It("ignores rules of other kinds", func() {
+		updateLive(func(live *appsv1.Deployment) {
+			scale := int32(3)
+			live.Spec.Replicas = &scale
+		})
+
+		otherRule := replicasRule
+		otherRule.Kind = "StatefulSet"
+		op, err := CreateOrUpdate(context.TODO(), deploymentCli, deployUns, deploymentSpecr(deployUns, deplSpec), WithIgnoreDifferences(otherRule))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+		Expect(*getLive().Spec.Replicas).To(BeEquivalentTo(1))
+	})
+
+	table.DescribeTable("resolves JSONPath expressions to pointers",
+		func(expr string, expected ...string) {
+			doc := map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{"example.com/a": "b", "c": "d"},
+				},
+				"spec": map[string]interface{}{
+					"replicas": int64(2),
+					"containers": []interface{}{
+						map[string]interface{}{"name": "app", "port": int64(80)},
+						map[string]interface{}{"name": "sidecar", "port": int64(8080), "injected": true},
+					},
+				},
+			}
+			pointers, err := jsonPathPointers(doc, expr)
+			Expect(err).NotTo(HaveOccurred())
+			if len(expected) == 0 {
+				Expect(pointers).To(BeEmpty())
+			} else {
+				Expect(pointers).To(Equal(expected))
+			}
+		},
+		table.Entry("field", ".spec.replicas", "/spec/replicas"),
+		table.Entry("braced field", "{.spec.replicas}", "/spec/replicas"),
+		table.Entry("missing field", ".spec.paused"),
+		table.Entry("escaped dot", `.metadata.annotations.example\.com/a`, "/metadata/annotations/example.com~1a"),
+		table.Entry("quoted key", ".metadata.annotations['c']", "/metadata/annotations/c"),
+		table.Entry("wildcard", ".metadata.annotations.*", "/metadata/annotations/c", "/metadata/annotations/example.com~1a"),
+		table.Entry("index", ".spec.containers[1].name", "/spec/containers/1/name"),
+		table.Entry("all elements", ".spec.containers[*].name", "/spec/containers/0/name", "/spec/containers/1/name"),
+		table.Entry("string filter", `.spec.containers[?(@.name=="sidecar")]`, "/spec/containers/1"),
+		table.Entry("number filter", `.spec.containers[?(@.port==80)].name`, "/spec/containers/0/name"),
+		table.Entry("not equal filter", `.spec.containers[?(@.name!="sidecar")]`, "/spec/containers/0"),
+		table.Entry("exists filter", `.spec.containers[?(@.injected)]`, "/spec/containers/1"),
+	)
+})
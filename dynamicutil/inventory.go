@@ -0,0 +1,294 @@
+package dynamicutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+const (
+	// PruneProtectionAnnotation opts an object out of pruning when set to
+	// PruneProtectionDetach: the object is kept in the cluster, and removed
+	// from the inventory. It's the annotation used by kpt and cli-utils.
+	PruneProtectionAnnotation = "client.lifecycle.config.k8s.io/deletion"
+	// PruneProtectionDetach is the value of PruneProtectionAnnotation which
+	// protects the object
+	PruneProtectionDetach = "detach"
+
+	// inventoryKey is the key of the entries in the data of the inventory
+	inventoryKey = "inventory"
+)
+
+// InventoryEntry identifies an object recorded in an inventory
+type InventoryEntry struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Name       string    `json:"name"`
+	UID        types.UID `json:"uid,omitempty"`
+}
+
+// Identity returns the identity of the object, regardless of its UID
+func (e InventoryEntry) Identity() ObjIdentity {
+	return ObjIdentity{
+		GroupKind: schema.FromAPIVersionAndKind(e.APIVersion, e.Kind).GroupKind(),
+		Namespace: e.Namespace,
+		Name:      e.Name,
+	}
+}
+
+// object returns an unstructured object with the identity of the entry
+func (e InventoryEntry) object() *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(e.APIVersion)
+	obj.SetKind(e.Kind)
+	obj.SetNamespace(e.Namespace)
+	obj.SetName(e.Name)
+	return obj
+}
+
+func inventoryEntryFromObject(obj *unstructured.Unstructured) InventoryEntry {
+	return InventoryEntry{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		UID:        obj.GetUID(),
+	}
+}
+
+// Inventory is the ConfigMap, or the Secret, recording the objects applied
+// by ApplyWithInventory, so the objects which disappear from the desired
+// objects are pruned on the next apply.
+type Inventory struct {
+	Namespace string
+	Name      string
+	// Secret stores the inventory in a Secret instead of a ConfigMap
+	Secret bool
+}
+
+// object returns the empty ConfigMap or Secret of the inventory
+func (inv Inventory) object() Object {
+	// TypeMeta is set so the Client doesn't need core/v1 in its scheme
+	objectMeta := metav1.ObjectMeta{Namespace: inv.Namespace, Name: inv.Name}
+	if inv.Secret {
+		return &corev1.Secret{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"}, ObjectMeta: objectMeta}
+	}
+	return &corev1.ConfigMap{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"}, ObjectMeta: objectMeta}
+}
+
+// Load returns the entries of the inventory, none if it doesn't exist yet
+func (inv Inventory) Load(ctx context.Context, c *Client) ([]InventoryEntry, error) {
+	obj := inv.object()
+	ri, err := c.Resource(obj)
+	if err != nil {
+		return nil, err
+	}
+	fetchedUns, err := ri.Namespace(inv.Namespace).Get(ctx, inv.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err = objectFromUnstructured(fetchedUns, obj); err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	switch obj := obj.(type) {
+	case *corev1.Secret:
+		data = obj.Data[inventoryKey]
+	case *corev1.ConfigMap:
+		data = []byte(obj.Data[inventoryKey])
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var entries []InventoryEntry
+	if err = json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid inventory %s/%s: %w", inv.Namespace, inv.Name, err)
+	}
+	return entries, nil
+}
+
+// Store replaces the entries of the inventory, which is created if needed
+func (inv Inventory) Store(ctx context.Context, c *Client, entries []InventoryEntry, opts ...Option) error {
+	sorted := append([]InventoryEntry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Identity().Less(sorted[j].Identity())
+	})
+	data, err := json.Marshal(sorted)
+	if err != nil {
+		return err
+	}
+
+	obj := inv.object()
+	_, err = c.CreateOrUpdate(ctx, obj, func() error {
+		switch obj := obj.(type) {
+		case *corev1.Secret:
+			if obj.Data == nil {
+				obj.Data = make(map[string][]byte, 1)
+			}
+			obj.Data[inventoryKey] = data
+		case *corev1.ConfigMap:
+			if obj.Data == nil {
+				obj.Data = make(map[string]string, 1)
+			}
+			obj.Data[inventoryKey] = string(data)
+		}
+		return nil
+	}, opts...)
+	return err
+}
+
+// InventoryOptions contains the options of ApplyWithInventory
+type InventoryOptions struct {
+	ObjectSetOptions
+	// PropagationPolicy is the propagation policy of the deletion of the
+	// pruned objects. Nil leaves the default policy of their resource.
+	PropagationPolicy *metav1.DeletionPropagation
+	// DryRun previews the apply and the prune without persisting them, the
+	// inventory isn't updated either.
+	DryRun DryRunMode
+}
+
+// PruneResult is the outcome of pruning one object of the inventory
+type PruneResult struct {
+	Entry InventoryEntry
+	// Result is OperationResultDeleted, or OperationResultNone if the object
+	// was already gone, has been replaced by another object with the same
+	// name, or is protected.
+	Result OperationResult
+	// Protected tells that the object has been kept because of the
+	// PruneProtectionAnnotation
+	Protected bool
+	// Err is the error pruning the object, if any
+	Err error
+}
+
+// InventoryResult is the outcome of ApplyWithInventory
+type InventoryResult struct {
+	// Applied are the results of the objects, in their order
+	Applied []ManifestResult
+	// Pruned are the results of the objects removed from the inventory
+	Pruned []PruneResult
+}
+
+// ApplyWithInventory applies the objects with ApplyObjectSet, then prunes the
+// objects recorded in the inventory which aren't part of the objects
+// anymore, and records the objects in the inventory.
+//
+// Objects are identified by their group, kind, namespace and name, an object
+// recorded with another UID than the existing one isn't pruned. The objects
+// are pruned in the reverse order of their creation, the protected ones are
+// detached: kept in the cluster and removed from the inventory. The objects
+// which failed to be applied or pruned are kept in the inventory.
+func ApplyWithInventory(ctx context.Context, c *Client, inv Inventory, objects []*unstructured.Unstructured, opts InventoryOptions) (*InventoryResult, error) {
+	previous, err := inv.Load(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	setOpts := opts.ObjectSetOptions
+	if opts.DryRun != DryRunNone {
+		setOpts.Options = append(setOpts.Options[:len(setOpts.Options):len(setOpts.Options)], WithDryRun(opts.DryRun))
+	}
+	applied, applyErr := ApplyObjectSet(ctx, c, objects, setOpts)
+	result := &InventoryResult{Applied: applied}
+	var errs []error
+	if applyErr != nil {
+		errs = append(errs, applyErr)
+	}
+
+	previousEntries := make(map[ObjIdentity]InventoryEntry, len(previous))
+	for _, entry := range previous {
+		previousEntries[entry.Identity()] = entry
+	}
+	desired := make(ObjIdentitySet, len(objects))
+	var entries []InventoryEntry
+	for i, obj := range objects {
+		id := ObjIdentityFromUnstructured(obj)
+		desired.Insert(id)
+		if applied[i].Err == nil {
+			entries = append(entries, inventoryEntryFromObject(applied[i].Object))
+		} else if entry, ok := previousEntries[id]; ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	var stale []*unstructured.Unstructured
+	for _, entry := range previous {
+		if !desired.Has(entry.Identity()) {
+			obj := entry.object()
+			obj.SetUID(entry.UID)
+			stale = append(stale, obj)
+		}
+	}
+	groups := groupByKindOrder(stale)
+	for i := len(groups) - 1; i >= 0; i-- {
+		for _, index := range groups[i] {
+			pruned := prune(ctx, c, stale[index], opts)
+			if pruned.Err != nil {
+				errs = append(errs, pruned.Err)
+				entries = append(entries, pruned.Entry)
+			}
+			result.Pruned = append(result.Pruned, pruned)
+		}
+	}
+
+	if opts.DryRun == DryRunNone {
+		if err = inv.Store(ctx, c, entries); err != nil {
+			errs = append(errs, fmt.Errorf("storing the inventory: %w", err))
+		}
+	}
+	return result, utilerrors.Flatten(utilerrors.NewAggregate(errs))
+}
+
+// prune deletes the object recorded in the inventory, unless it's protected
+// or it has been replaced.
+func prune(ctx context.Context, c *Client, obj *unstructured.Unstructured, opts InventoryOptions) PruneResult {
+	result := PruneResult{Entry: inventoryEntryFromObject(obj), Result: OperationResultNone}
+	ri, err := c.Resource(obj)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	fetchedUns, err := ri.Namespace(obj.GetNamespace()).Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			result.Err = fmt.Errorf("%s %s: %w", obj.GetKind(), namespacedNameFromObject(obj), err)
+		}
+		return result
+	}
+	if obj.GetUID() != "" && fetchedUns.GetUID() != obj.GetUID() {
+		return result
+	}
+	if fetchedUns.GetAnnotations()[PruneProtectionAnnotation] == PruneProtectionDetach {
+		result.Protected = true
+		return result
+	}
+
+	deleteOpts := DeleteOptions{
+		PropagationPolicy: opts.PropagationPolicy,
+		DryRun:            opts.DryRun,
+	}
+	if uid := fetchedUns.GetUID(); uid != "" {
+		// The object may be replaced in the meantime
+		deleteOpts.Preconditions = &metav1.Preconditions{UID: &uid}
+	}
+	result.Result, err = Delete(ctx, ri, fetchedUns, deleteOpts)
+	if err != nil {
+		result.Err = fmt.Errorf("%s %s: %w", obj.GetKind(), namespacedNameFromObject(obj), err)
+	}
+	return result
+}
@@ -0,0 +1,171 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("Inventory", func() {
+	var c *Client
+	var inv Inventory
+	var prefix string
+
+	BeforeEach(func() {
+		mapper := meta.NewDefaultRESTMapper(nil)
+		mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+		mapper.Add(corev1.SchemeGroupVersion.WithKind("Secret"), meta.RESTScopeNamespace)
+		c = NewClient(dynClient, mapper, clientgoscheme.Scheme)
+
+		prefix = fmt.Sprintf("inv-%d", rand.Int31())
+		inv = Inventory{Namespace: "default", Name: prefix}
+	})
+
+	configMap := func(name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace("default")
+		obj.SetName(prefix + "-" + name)
+		Expect(unstructured.SetNestedStringMap(obj.Object, map[string]string{"foo": name}, "data")).To(Succeed())
+		return obj
+	}
+	names := func(entries []InventoryEntry) []string {
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name)
+		}
+		return names
+	}
+	exists := func(obj *unstructured.Unstructured) bool {
+		ri, err := c.Resource(obj)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = ri.Namespace(obj.GetNamespace()).Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+		return err == nil
+	}
+
+	It("stores and loads the entries", func() {
+		entries, err := inv.Load(context.TODO(), c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+
+		stored := []InventoryEntry{
+			{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "b", UID: "uid-b"},
+			{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "a"},
+		}
+		Expect(inv.Store(context.TODO(), c, stored)).To(Succeed())
+		entries, err = inv.Load(context.TODO(), c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(Equal([]InventoryEntry{stored[0], stored[1]}))
+
+		By("storing them in a Secret")
+		inv.Secret = true
+		Expect(inv.Store(context.TODO(), c, stored)).To(Succeed())
+		entries, err = inv.Load(context.TODO(), c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+	})
+
+	It("stores and loads the entries without a scheme", func() {
+		mapper := meta.NewDefaultRESTMapper(nil)
+		mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+		mapper.Add(corev1.SchemeGroupVersion.WithKind("Secret"), meta.RESTScopeNamespace)
+		c = NewClient(dynClient, mapper, nil)
+
+		stored := []InventoryEntry{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "a"}}
+		Expect(inv.Store(context.TODO(), c, stored)).To(Succeed())
+		entries, err := inv.Load(context.TODO(), c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(Equal(stored))
+
+		By("storing them in a Secret")
+		inv.Secret = true
+		Expect(inv.Store(context.TODO(), c, stored)).To(Succeed())
+		entries, err = inv.Load(context.TODO(), c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(Equal(stored))
+	})
+
+	It("prunes the objects which aren't desired anymore", func() {
+		a, b := configMap("a"), configMap("b")
+		result, err := ApplyWithInventory(context.TODO(), c, inv, []*unstructured.Unstructured{a, b}, InventoryOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Applied).To(HaveLen(2))
+		Expect(result.Pruned).To(BeEmpty())
+		entries, err := inv.Load(context.TODO(), c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(entries)).To(Equal([]string{a.GetName(), b.GetName()}))
+
+		result, err = ApplyWithInventory(context.TODO(), c, inv, []*unstructured.Unstructured{a}, InventoryOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Pruned).To(HaveLen(1))
+		Expect(result.Pruned[0].Entry.Name).To(Equal(b.GetName()))
+		Expect(result.Pruned[0].Result).To(BeEquivalentTo(OperationResultDeleted))
+		Expect(exists(b)).To(BeFalse())
+		entries, err = inv.Load(context.TODO(), c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(entries)).To(Equal([]string{a.GetName()}))
+	})
+
+	It("previews the prune on dry-run", func() {
+		a, b := configMap("a"), configMap("b")
+		_, err := ApplyWithInventory(context.TODO(), c, inv, []*unstructured.Unstructured{a, b}, InventoryOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		result, err := ApplyWithInventory(context.TODO(), c, inv, []*unstructured.Unstructured{a}, InventoryOptions{DryRun: DryRunClient})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Pruned).To(HaveLen(1))
+		Expect(result.Pruned[0].Result).To(BeEquivalentTo(OperationResultDeleted))
+		Expect(exists(b)).To(BeTrue())
+		entries, err := inv.Load(context.TODO(), c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+	})
+
+	It("detaches the protected objects", func() {
+		a, b := configMap("a"), configMap("b")
+		b.SetAnnotations(map[string]string{PruneProtectionAnnotation: PruneProtectionDetach})
+		_, err := ApplyWithInventory(context.TODO(), c, inv, []*unstructured.Unstructured{a, b}, InventoryOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		result, err := ApplyWithInventory(context.TODO(), c, inv, []*unstructured.Unstructured{a}, InventoryOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Pruned).To(HaveLen(1))
+		Expect(result.Pruned[0].Protected).To(BeTrue())
+		Expect(result.Pruned[0].Result).To(BeEquivalentTo(OperationResultNone))
+		Expect(exists(b)).To(BeTrue())
+		entries, err := inv.Load(context.TODO(), c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(entries)).To(Equal([]string{a.GetName()}))
+	})
+
+	It("keeps the objects which failed to be applied in the inventory", func() {
+		a := configMap("a")
+		secret := &unstructured.Unstructured{}
+		secret.SetAPIVersion("v1")
+		secret.SetKind("Secret")
+		secret.SetNamespace("default")
+		secret.SetName(prefix + "-secret")
+		_, err := ApplyWithInventory(context.TODO(), c, inv, []*unstructured.Unstructured{a, secret}, InventoryOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("failing to resolve the kind of the Secret")
+		mapper := meta.NewDefaultRESTMapper(nil)
+		mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+		c = NewClient(dynClient, mapper, clientgoscheme.Scheme)
+		result, err := ApplyWithInventory(context.TODO(), c, inv, []*unstructured.Unstructured{a, secret}, InventoryOptions{})
+		Expect(err).To(HaveOccurred())
+		Expect(result.Applied[1].Err).To(HaveOccurred())
+		Expect(result.Pruned).To(BeEmpty())
+		entries, err := inv.Load(context.TODO(), c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(entries)).To(Equal([]string{a.GetName(), secret.GetName()}))
+	})
+})
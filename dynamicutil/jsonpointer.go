@@ -0,0 +1,170 @@
+package dynamicutil
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	jsonPointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	jsonPointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// splitPointer returns the unescaped reference tokens of an RFC 6901 JSON
+// pointer.
+func splitPointer(pointer string) []string {
+	if pointer == "" {
+		return nil
+	}
+
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, token := range tokens {
+		tokens[i] = jsonPointerUnescaper.Replace(token)
+	}
+	return tokens
+}
+
+// appendPointer returns the pointer of the child named token of pointer
+func appendPointer(pointer string, token string) string {
+	return pointer + "/" + jsonPointerEscaper.Replace(token)
+}
+
+// valueAtPointer returns the value at the RFC 6901 JSON pointer in doc
+func valueAtPointer(doc interface{}, pointer string) (interface{}, bool) {
+	current := doc
+	for _, token := range splitPointer(pointer) {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[token]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			current = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// setAtPointer sets the value at the JSON pointer in doc, missing parent
+// objects are created. An array element may be appended by pointing at the
+// index just past the end of the array.
+func setAtPointer(doc map[string]interface{}, pointer string, value interface{}) error {
+	tokens := splitPointer(pointer)
+	if len(tokens) == 0 {
+		return fmt.Errorf("cannot set the root of the document")
+	}
+	_, err := setIn(doc, tokens, value)
+	return err
+}
+
+func setIn(node interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	token, rest := tokens[0], tokens[1:]
+	switch v := node.(type) {
+	case nil:
+		child, err := setIn(nil, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{token: child}, nil
+	case map[string]interface{}:
+		child, err := setIn(v[token], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		v[token] = child
+		return v, nil
+	case []interface{}:
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i > len(v) {
+			return nil, fmt.Errorf("invalid index %q of an array of length %d", token, len(v))
+		}
+		if i == len(v) {
+			child, err := setIn(nil, rest, value)
+			if err != nil {
+				return nil, err
+			}
+			return append(v, child), nil
+		}
+		child, err := setIn(v[i], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = child
+		return v, nil
+	default:
+		return nil, fmt.Errorf("cannot set %q in a %T", token, node)
+	}
+}
+
+// removeAtPointer removes the value at the JSON pointer from doc, it does
+// nothing if there's no such value.
+func removeAtPointer(doc map[string]interface{}, pointer string) {
+	tokens := splitPointer(pointer)
+	if len(tokens) == 0 {
+		return
+	}
+	removeIn(doc, tokens)
+}
+
+func removeIn(node interface{}, tokens []string) interface{} {
+	token, rest := tokens[0], tokens[1:]
+	switch v := node.(type) {
+	case map[string]interface{}:
+		child, ok := v[token]
+		if !ok {
+			return v
+		}
+		if len(rest) == 0 {
+			delete(v, token)
+		} else {
+			v[token] = removeIn(child, rest)
+		}
+		return v
+	case []interface{}:
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i >= len(v) {
+			return v
+		}
+		if len(rest) == 0 {
+			return append(v[:i:i], v[i+1:]...)
+		}
+		v[i] = removeIn(v[i], rest)
+		return v
+	default:
+		return node
+	}
+}
+
+// sortPointers sorts pointers in document order, array indices are compared
+// numerically.
+func sortPointers(pointers []string) {
+	sort.Slice(pointers, func(i, j int) bool {
+		a, b := splitPointer(pointers[i]), splitPointer(pointers[j])
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] == b[k] {
+				continue
+			}
+			ai, aErr := strconv.Atoi(a[k])
+			bi, bErr := strconv.Atoi(b[k])
+			if aErr == nil && bErr == nil {
+				return ai < bi
+			}
+			return a[k] < b[k]
+		}
+		return len(a) < len(b)
+	})
+}
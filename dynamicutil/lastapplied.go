@@ -0,0 +1,155 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/dynamic"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// LastAppliedConfigAnnotation is the annotation storing the last applied
+// configuration of an object, like kubectl apply does
+const LastAppliedConfigAnnotation = corev1.LastAppliedConfigAnnotation
+
+// ClientSideApplyOptions contains the options of a ClientSideApply call
+type ClientSideApplyOptions struct {
+	// Scheme is used to set the apiVersion and kind of typed objects, see
+	// WithScheme.
+	Scheme *runtime.Scheme
+	// DryRun previews the apply without persisting it, see DryRunMode.
+	DryRun DryRunMode
+}
+
+// ClientSideApply applies the given object to the Kubernetes cluster like
+// kubectl apply, for clusters or tools without server-side apply.
+//
+// The object is stored in the LastAppliedConfigAnnotation, and the existing
+// object is patched with a three-way merge of the last applied object, the
+// given object and the existing object: the fields removed from the given
+// object since the last apply are removed, while the fields set by others
+// are kept. Built-in kinds are patched with strategic merge patches, lists
+// like the containers of a pod being merged by key, other kinds with JSON
+// merge patches. The status isn't applied.
+//
+// On success the object returned by the API server is decoded back into obj.
+// It returns the executed operation and an error.
+func ClientSideApply(ctx context.Context, c dynamic.NamespaceableResourceInterface, obj Object, opts ClientSideApplyOptions) (OperationResult, error) {
+	key := namespacedNameFromObject(obj)
+	cli := c.Namespace(key.Namespace)
+
+	objUns, err := stampedUnstructuredFromObject(opts.Scheme, obj.DeepCopyObject())
+	if err != nil {
+		return OperationResultNone, err
+	}
+	if objUns.GetAPIVersion() == "" || objUns.GetKind() == "" {
+		return OperationResultNone, fmt.Errorf("apiVersion and kind are required to apply %s", key)
+	}
+	modified, err := setLastAppliedConfig(objUns)
+	if err != nil {
+		return OperationResultNone, err
+	}
+
+	existingUns, err := cli.Get(ctx, key.Name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return OperationResultNone, err
+		}
+		if opts.DryRun == DryRunClient {
+			return OperationResultCreated, nil
+		}
+		fetchedUns, err := cli.Create(ctx, objUns, metav1.CreateOptions{DryRun: dryRunOption(opts.DryRun)})
+		if err != nil {
+			return OperationResultNone, err
+		}
+		if err = objectFromUnstructured(fetchedUns, obj); err != nil {
+			return OperationResultNone, err
+		}
+		return OperationResultCreated, nil
+	}
+
+	current, err := existingUns.MarshalJSON()
+	if err != nil {
+		return OperationResultNone, err
+	}
+	original := []byte(existingUns.GetAnnotations()[LastAppliedConfigAnnotation])
+	patchType, patch, err := threeWayMergePatch(objUns.GroupVersionKind(), original, modified, current)
+	if err != nil {
+		return OperationResultNone, fmt.Errorf("computing the patch of %s: %w", key, err)
+	}
+	if string(patch) == "{}" {
+		if err = objectFromUnstructured(existingUns, obj); err != nil {
+			return OperationResultNone, err
+		}
+		return OperationResultNone, nil
+	}
+	if opts.DryRun == DryRunClient {
+		return OperationResultUpdated, nil
+	}
+
+	fetchedUns, err := cli.Patch(ctx, key.Name, patchType, patch, metav1.PatchOptions{DryRun: dryRunOption(opts.DryRun)})
+	if err != nil {
+		return OperationResultNone, err
+	}
+	if err = objectFromUnstructured(fetchedUns, obj); err != nil {
+		return OperationResultNone, err
+	}
+	switch {
+	case opts.DryRun == DryRunServer && dryRunChanged(existingUns, fetchedUns):
+		return OperationResultUpdated, nil
+	case opts.DryRun != DryRunServer && fetchedUns.GetResourceVersion() != existingUns.GetResourceVersion():
+		return OperationResultUpdated, nil
+	default:
+		return OperationResultNone, nil
+	}
+}
+
+// setLastAppliedConfig sets the LastAppliedConfigAnnotation of objUns to
+// objUns without the annotation, and returns the JSON of objUns with the
+// annotation: the modified configuration of the three-way merge.
+func setLastAppliedConfig(objUns *unstructured.Unstructured) ([]byte, error) {
+	// The fields set by the API server aren't part of the configuration, nor
+	// the status, which typed objects always have.
+	clearServerMetadata(objUns)
+	unstructured.RemoveNestedField(objUns.Object, "status")
+
+	annotations := objUns.GetAnnotations()
+	delete(annotations, LastAppliedConfigAnnotation)
+	objUns.SetAnnotations(annotations)
+	applied, err := objUns.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	if annotations == nil {
+		annotations = make(map[string]string, 1)
+	}
+	annotations[LastAppliedConfigAnnotation] = string(applied)
+	objUns.SetAnnotations(annotations)
+	return objUns.MarshalJSON()
+}
+
+// threeWayMergePatch returns the patch from current to modified, deleting
+// the fields of original which aren't in modified anymore. It's a strategic
+// merge patch for the built-in kinds, a JSON merge patch otherwise.
+func threeWayMergePatch(gvk schema.GroupVersionKind, original, modified, current []byte) (types.PatchType, []byte, error) {
+	patchMeta, err := patchMetaForKind(clientgoscheme.Scheme, gvk)
+	if err != nil {
+		return "", nil, err
+	}
+	if patchMeta == nil {
+		patch, err := jsonmergepatch.CreateThreeWayJSONMergePatch(original, modified, current)
+		return types.MergePatchType, patch, err
+	}
+	patch, err := strategicpatch.CreateThreeWayMergePatch(original, modified, current, patchMeta, true)
+	return types.StrategicMergePatchType, patch, err
+}
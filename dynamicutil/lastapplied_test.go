@@ -0,0 +1,141 @@
+package dynamicutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/dynamic"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("ClientSideApply", func() {
+	var configMapCli dynamic.NamespaceableResourceInterface
+	var name string
+
+	BeforeEach(func() {
+		configMapCli = dynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+		name = fmt.Sprintf("cm-%d", rand.Int31())
+	})
+
+	desired := func(data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data:       data,
+		}
+	}
+	opts := ClientSideApplyOptions{Scheme: clientgoscheme.Scheme}
+
+	It("creates the object with its last applied configuration", func() {
+		cm := desired(map[string]string{"foo": "bar"})
+		op, err := ClientSideApply(context.TODO(), configMapCli, cm, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+		var applied map[string]interface{}
+		Expect(json.Unmarshal([]byte(cm.Annotations[LastAppliedConfigAnnotation]), &applied)).To(Succeed())
+		Expect(applied).To(Equal(map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+			"data":       map[string]interface{}{"foo": "bar"},
+		}))
+	})
+
+	It("removes the fields removed since the last apply and keeps the fields set by others", func() {
+		_, err := ClientSideApply(context.TODO(), configMapCli, desired(map[string]string{"foo": "bar", "removed": "value"}), opts)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("setting fields as another actor")
+		other := desired(nil)
+		_, err = CreateOrUpdate(context.TODO(), configMapCli, other, func() error {
+			other.Labels = map[string]string{"other": "label"}
+			other.Data["other"] = "value"
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		cm := desired(map[string]string{"foo": "baz"})
+		op, err := ClientSideApply(context.TODO(), configMapCli, cm, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+		Expect(cm.Data).To(Equal(map[string]string{"foo": "baz", "other": "value"}))
+		Expect(cm.Labels).To(Equal(map[string]string{"other": "label"}))
+
+		By("applying the object returned by the API server again")
+		op, err = ClientSideApply(context.TODO(), configMapCli, cm, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+	})
+
+	It("previews the changes on client-side dry-run", func() {
+		_, err := ClientSideApply(context.TODO(), configMapCli, desired(map[string]string{"foo": "bar"}), opts)
+		Expect(err).NotTo(HaveOccurred())
+
+		dryRunOpts := opts
+		dryRunOpts.DryRun = DryRunClient
+		op, err := ClientSideApply(context.TODO(), configMapCli, desired(map[string]string{"foo": "baz"}), dryRunOpts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+
+		fetched, err := configMapCli.Namespace("default").Get(context.TODO(), name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched.Object).To(HaveKeyWithValue("data", map[string]interface{}{"foo": "bar"}))
+	})
+
+	Describe("three-way merge patch", func() {
+		deployment := func(containers ...corev1.Container) []byte {
+			data, err := json.Marshal(&appsv1.Deployment{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: containers}},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			return data
+		}
+
+		It("merges the lists of built-in kinds by key", func() {
+			original := deployment(corev1.Container{Name: "app", Image: "app:v1"})
+			modified := deployment(corev1.Container{Name: "app", Image: "app:v2"})
+			current := deployment(corev1.Container{Name: "app", Image: "app:v1"}, corev1.Container{Name: "sidecar", Image: "sidecar"})
+
+			patchType, patch, err := threeWayMergePatch(appsv1.SchemeGroupVersion.WithKind("Deployment"), original, modified, current)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patchType).To(Equal(types.StrategicMergePatchType))
+
+			patched, err := strategicpatch.StrategicMergePatch(current, patch, &appsv1.Deployment{})
+			Expect(err).NotTo(HaveOccurred())
+			var deploy appsv1.Deployment
+			Expect(json.Unmarshal(patched, &deploy)).To(Succeed())
+			Expect(deploy.Spec.Template.Spec.Containers).To(Equal([]corev1.Container{
+				{Name: "app", Image: "app:v2"},
+				{Name: "sidecar", Image: "sidecar"},
+			}))
+		})
+
+		It("uses JSON merge patches for the other kinds", func() {
+			original := []byte(`{"apiVersion": "example.com/v1", "kind": "Widget", "spec": {"size": 1, "color": "red"}}`)
+			modified := []byte(`{"apiVersion": "example.com/v1", "kind": "Widget", "spec": {"size": 2}}`)
+			current := []byte(`{"apiVersion": "example.com/v1", "kind": "Widget", "spec": {"size": 1, "color": "red", "shape": "round"}}`)
+
+			patchType, patch, err := threeWayMergePatch(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, original, modified, current)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patchType).To(Equal(types.MergePatchType))
+
+			patched, err := jsonpatch.MergePatch(current, patch)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patched).To(MatchJSON(`{"apiVersion": "example.com/v1", "kind": "Widget", "spec": {"size": 2, "shape": "round"}}`))
+		})
+	})
+})
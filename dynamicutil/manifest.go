@@ -0,0 +1,237 @@
+package dynamicutil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// manifestExtensions are the extensions of the files read from directories
+var manifestExtensions = []string{".yaml", ".yml", ".json"}
+
+// ManifestResult is the outcome of applying one object of a manifest
+type ManifestResult struct {
+	// Object is the object as returned by the API server, or the desired
+	// object if it couldn't be applied
+	Object *unstructured.Unstructured
+	// Result is the executed operation
+	Result OperationResult
+	// Err is the error applying the object, if any
+	Err error
+}
+
+// DecodeManifests decodes the objects of a stream of YAML documents separated
+// by "---", or of concatenated JSON documents. Empty documents are skipped and
+// lists, like v1/List, are expanded into their items.
+//
+// Documents with duplicate keys are rejected instead of silently keeping the
+// last value.
+func DecodeManifests(r io.Reader) ([]*unstructured.Unstructured, error) {
+	docs, err := splitManifestDocuments(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*unstructured.Unstructured
+	for i, doc := range docs {
+		decoded, err := decodeManifestDocument(doc)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		objects = append(objects, decoded...)
+	}
+	return objects, nil
+}
+
+// ReadManifestFile decodes the objects of the manifest file at path, see
+// DecodeManifests.
+func ReadManifestFile(path string) ([]*unstructured.Unstructured, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	objects, err := DecodeManifests(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return objects, nil
+}
+
+// ReadManifestDir decodes the objects of the .yaml, .yml and .json files of
+// dir, in the lexical order of the file names. Subdirectories are ignored.
+func ReadManifestDir(dir string) ([]*unstructured.Unstructured, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*unstructured.Unstructured
+	for _, info := range infos {
+		if info.IsDir() || !isManifestFile(info.Name()) {
+			continue
+		}
+		decoded, err := ReadManifestFile(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+	return objects, nil
+}
+
+// ApplyManifests creates or updates the objects in order, like kubectl
+// apply: the manifests are stored in the LastAppliedConfigAnnotation, and the
+// existing objects are updated with a three-way merge of the last applied
+// manifest, the manifest and the existing object, see ClientSideApply. The
+// fields removed from a manifest since the last apply are removed, while the
+// fields set by others, like the defaults of the API server, are kept, so
+// applying the same manifests again reports OperationResultNone. The status
+// isn't applied, and the objects are not modified.
+//
+// All the objects are applied even if some fail, the results are in the
+// order of the objects, and the returned error aggregates their errors.
+func ApplyManifests(ctx context.Context, c *Client, objects []*unstructured.Unstructured, opts ...Option) ([]ManifestResult, error) {
+	results := make([]ManifestResult, 0, len(objects))
+	for _, desired := range objects {
+		results = append(results, applyManifest(ctx, c, desired, opts))
+	}
+	return results, manifestErrors(results)
+}
+
+// applyManifest creates or updates the object from desired, see
+// ApplyManifests.
+func applyManifest(ctx context.Context, c *Client, desired *unstructured.Unstructured, opts []Option) ManifestResult {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(desired.GroupVersionKind())
+	obj.SetNamespace(desired.GetNamespace())
+	obj.SetName(desired.GetName())
+
+	result, err := c.CreateOrUpdate(ctx, obj, func() error {
+		return setManifestFields(obj, desired)
+	}, opts...)
+	if err != nil {
+		err = fmt.Errorf("%s %s: %w", desired.GetKind(), namespacedNameFromObject(desired), err)
+		obj = desired.DeepCopy()
+	}
+	return ManifestResult{Object: obj, Result: result, Err: err}
+}
+
+// manifestErrors aggregates the errors of the results
+func manifestErrors(results []ManifestResult) error {
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// setManifestFields sets the fields of desired on obj like kubectl apply, see
+// ApplyManifests.
+func setManifestFields(obj, desired *unstructured.Unstructured) error {
+	modified, err := setLastAppliedConfig(desired.DeepCopy())
+	if err != nil {
+		return err
+	}
+	current, err := obj.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	original := []byte(obj.GetAnnotations()[LastAppliedConfigAnnotation])
+	_, patch, err := threeWayMergePatch(desired.GroupVersionKind(), original, modified, current)
+	if err != nil {
+		return err
+	}
+	patched, err := StrategicMergePatch(clientgoscheme.Scheme, obj, patch)
+	if err != nil {
+		return err
+	}
+	obj.Object = patched.Object
+	return nil
+}
+
+func isManifestFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, manifestExt := range manifestExtensions {
+		if ext == manifestExt {
+			return true
+		}
+	}
+	return false
+}
+
+// splitManifestDocuments splits the stream into its raw YAML or JSON
+// documents.
+func splitManifestDocuments(r io.Reader) ([][]byte, error) {
+	r, _, isJSON := utilyaml.GuessJSONStream(r, 4096)
+	if isJSON {
+		var docs [][]byte
+		decoder := json.NewDecoder(r)
+		for {
+			var doc json.RawMessage
+			if err := decoder.Decode(&doc); err == io.EOF {
+				return docs, nil
+			} else if err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+	}
+
+	var docs [][]byte
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return docs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// decodeManifestDocument decodes the objects of a YAML or JSON document. JSON
+// is parsed as YAML too, so duplicate keys are detected the same way.
+func decodeManifestDocument(doc []byte) ([]*unstructured.Unstructured, error) {
+	data, err := yaml.YAMLToJSONStrict(doc)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil, nil
+	}
+
+	decoded, _, err := unstructured.UnstructuredJSONScheme.Decode(data, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch decoded := decoded.(type) {
+	case *unstructured.Unstructured:
+		return []*unstructured.Unstructured{decoded}, nil
+	case *unstructured.UnstructuredList:
+		objects := make([]*unstructured.Unstructured, 0, len(decoded.Items))
+		for i := range decoded.Items {
+			objects = append(objects, &decoded.Items[i])
+		}
+		return objects, nil
+	default:
+		return nil, fmt.Errorf("unexpected %T", decoded)
+	}
+}
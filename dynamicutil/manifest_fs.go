@@ -0,0 +1,40 @@
+//go:build go1.16
+// +build go1.16
+
+package dynamicutil
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ReadManifestFS is ReadManifestDir reading the directory dir of fsys, e.g.
+// manifests embedded in the binary.
+func ReadManifestFS(fsys fs.FS, dir string) ([]*unstructured.Unstructured, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*unstructured.Unstructured
+	for _, entry := range entries {
+		if entry.IsDir() || !isManifestFile(entry.Name()) {
+			continue
+		}
+		name := path.Join(dir, entry.Name())
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := DecodeManifests(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		objects = append(objects, decoded...)
+	}
+	return objects, nil
+}
@@ -0,0 +1,32 @@
+//go:build go1.16
+// +build go1.16
+
+package dynamicutil
+
+import (
+	"testing/fstest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ReadManifestFS", func() {
+	It("reads the manifest files of a directory of the file system", func() {
+		fsys := fstest.MapFS{
+			"manifests/b.yaml":    {Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n")},
+			"manifests/a.json":    {Data: []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}}`)},
+			"manifests/README.md": {Data: []byte("not a manifest")},
+			"manifests/sub/c.yml": {Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n")},
+		}
+
+		objects, err := ReadManifestFS(fsys, "manifests")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objects).To(HaveLen(2))
+		Expect(objects[0].GetName()).To(Equal("a"))
+		Expect(objects[1].GetName()).To(Equal("b"))
+
+		By("telling which file is invalid")
+		fsys["manifests/c.yaml"] = &fstest.MapFile{Data: []byte("kind: [\n")}
+		_, err = ReadManifestFS(fsys, "manifests")
+		Expect(err).To(MatchError(ContainSubstring("manifests/c.yaml")))
+	})
+})
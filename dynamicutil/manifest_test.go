@@ -0,0 +1,262 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("Manifests", func() {
+	objectKeys := func(objects []*unstructured.Unstructured) []string {
+		keys := make([]string, 0, len(objects))
+		for _, obj := range objects {
+			keys = append(keys, obj.GetKind()+"/"+obj.GetName())
+		}
+		return keys
+	}
+
+	Describe("DecodeManifests", func() {
+		It("decodes YAML documents", func() {
+			objects, err := DecodeManifests(strings.NewReader(`
+# leading comment
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+data:
+  foo: bar
+---
+# empty document
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: bar
+- apiVersion: v1
+  kind: ServiceAccount
+  metadata:
+    name: baz
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objectKeys(objects)).To(Equal([]string{"ConfigMap/foo", "Secret/bar", "ServiceAccount/baz"}))
+			Expect(objects[0].Object).To(HaveKeyWithValue("data", map[string]interface{}{"foo": "bar"}))
+		})
+
+		It("decodes JSON documents", func() {
+			objects, err := DecodeManifests(strings.NewReader(`
+{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "foo"}}
+{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "bar"}}
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objectKeys(objects)).To(Equal([]string{"ConfigMap/foo", "Secret/bar"}))
+		})
+
+		It("rejects duplicate keys", func() {
+			_, err := DecodeManifests(strings.NewReader(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: bar
+data:
+  foo: bar
+  foo: baz
+`))
+			Expect(err).To(MatchError(ContainSubstring("document 1")))
+			Expect(err).To(MatchError(ContainSubstring(`"foo" already set`)))
+
+			_, err = DecodeManifests(strings.NewReader(`{"apiVersion": "v1", "kind": "ConfigMap", "kind": "Secret"}`))
+			Expect(err).To(MatchError(ContainSubstring(`"kind" already set`)))
+		})
+
+		It("rejects objects without kind", func() {
+			_, err := DecodeManifests(strings.NewReader(`
+apiVersion: v1
+metadata:
+  name: foo
+`))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	It("reads the manifest files of directories", func() {
+		dir, err := ioutil.TempDir("", "manifests")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		files := map[string]string{
+			"b.yaml":        "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n",
+			"a.json":        `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}}`,
+			"README.md":     "not a manifest",
+			"sub/c.yml":     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n",
+			"d.yml":         "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: d\n",
+			"sub/README.md": "",
+		}
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+			Expect(ioutil.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+		}
+
+		objects, err := ReadManifestDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objectKeys(objects)).To(Equal([]string{"ConfigMap/a", "ConfigMap/b", "ConfigMap/d"}))
+
+		By("telling which file is invalid")
+		Expect(ioutil.WriteFile(filepath.Join(dir, "e.yaml"), []byte("kind: [\n"), 0o644)).To(Succeed())
+		_, err = ReadManifestDir(dir)
+		Expect(err).To(MatchError(ContainSubstring("e.yaml")))
+	})
+
+	Describe("ApplyManifests", func() {
+		var c *Client
+		var name string
+
+		BeforeEach(func() {
+			mapper := meta.NewDefaultRESTMapper(nil)
+			mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+			mapper.Add(deploymentGVK, meta.RESTScopeNamespace)
+			c = NewClient(dynClient, mapper, clientgoscheme.Scheme)
+			name = fmt.Sprintf("cm-%d", rand.Int31())
+		})
+
+		manifests := func(value string) []*unstructured.Unstructured {
+			objects, err := DecodeManifests(strings.NewReader(fmt.Sprintf(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+  namespace: default
+  labels:
+    app: foo
+data:
+  foo: %s
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: %s
+  namespace: default
+`, name, value, name)))
+			Expect(err).NotTo(HaveOccurred())
+			return objects
+		}
+
+		It("applies the objects and reports the result of each", func() {
+			results, err := ApplyManifests(context.TODO(), c, manifests("bar"))
+			Expect(err).To(HaveOccurred())
+			Expect(results).To(HaveLen(2))
+			Expect(results[0].Err).NotTo(HaveOccurred())
+			Expect(results[0].Result).To(BeEquivalentTo(OperationResultCreated))
+			Expect(results[0].Object.Object).To(HaveKeyWithValue("data", map[string]interface{}{"foo": "bar"}))
+			Expect(results[1].Err).To(HaveOccurred())
+			Expect(results[1].Result).To(BeEquivalentTo(OperationResultNone))
+			Expect(results[1].Object.GetKind()).To(Equal("Widget"))
+			Expect(err.Error()).To(ContainSubstring("Widget default/" + name))
+
+			By("keeping the labels set by others")
+			configMapCli := dynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+			_, err = CreateOrUpdate(context.TODO(), configMapCli, cm, func() error {
+				cm.Labels["other"] = "label"
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("updating the objects")
+			results, _ = ApplyManifests(context.TODO(), c, manifests("baz"))
+			Expect(results[0].Err).NotTo(HaveOccurred())
+			Expect(results[0].Result).To(BeEquivalentTo(OperationResultUpdated))
+			Expect(results[0].Object.GetLabels()).To(Equal(map[string]string{"app": "foo", "other": "label"}))
+			Expect(results[0].Object.Object).To(HaveKeyWithValue("data", map[string]interface{}{"foo": "baz"}))
+
+			results, _ = ApplyManifests(context.TODO(), c, manifests("baz"))
+			Expect(results[0].Result).To(BeEquivalentTo(OperationResultNone))
+		})
+
+		It("doesn't update the objects defaulted by the API server", func() {
+			objects, err := DecodeManifests(strings.NewReader(fmt.Sprintf(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: %s
+  namespace: default
+spec:
+  selector:
+    matchLabels:
+      app: foo
+  template:
+    metadata:
+      labels:
+        app: foo
+    spec:
+      containers:
+      - name: app
+        image: busybox
+`, name)))
+			Expect(err).NotTo(HaveOccurred())
+
+			results, err := ApplyManifests(context.TODO(), c, objects)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results[0].Result).To(BeEquivalentTo(OperationResultCreated))
+
+			By("setting the defaults like the API server")
+			deploymentCli := dynClient.Resource(deploymentGVR)
+			deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+			_, err = CreateOrUpdate(context.TODO(), deploymentCli, deploy, func() error {
+				defaultDeployment(deploy)
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			results, err = ApplyManifests(context.TODO(), c, objects)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results[0].Result).To(BeEquivalentTo(OperationResultNone))
+		})
+
+		It("removes the fields removed from the manifests", func() {
+			configMap := func(binaryData string) []*unstructured.Unstructured {
+				objects, err := DecodeManifests(strings.NewReader(fmt.Sprintf(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+  namespace: default
+data:
+  foo: bar
+%s`, name, binaryData)))
+				Expect(err).NotTo(HaveOccurred())
+				return objects
+			}
+
+			_, err := ApplyManifests(context.TODO(), c, configMap("binaryData:\n  bin: YmFy\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			results, err := ApplyManifests(context.TODO(), c, configMap(""))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results[0].Result).To(BeEquivalentTo(OperationResultUpdated))
+			Expect(results[0].Object.Object).NotTo(HaveKey("binaryData"))
+			Expect(results[0].Object.Object).To(HaveKeyWithValue("data", map[string]interface{}{"foo": "bar"}))
+		})
+	})
+})
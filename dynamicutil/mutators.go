@@ -0,0 +1,243 @@
+package dynamicutil
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// podSpecPaths are the paths of the pod spec in the kinds with containers,
+// in the order they're looked up: CronJobs, the kinds with a pod template,
+// and Pods.
+var podSpecPaths = [][]string{
+	{"spec", "jobTemplate", "spec", "template", "spec"},
+	{"spec", "template", "spec"},
+	{"spec"},
+}
+
+// Mutator is a MutateFn which reports the fields it touched, as JSON
+// pointers like /metadata/labels/app. The fields which already have the
+// desired value aren't reported.
+type Mutator func() ([]string, error)
+
+// MutateFn adapts the Mutator to a MutateFn. Unless it's nil, touched is set
+// to the fields touched by the last invocation, as the MutateFn is invoked
+// again on retries.
+func (m Mutator) MutateFn(touched *[]string) MutateFn {
+	return func() error {
+		fields, err := m()
+		if touched != nil {
+			*touched = fields
+		}
+		return err
+	}
+}
+
+// FromMutateFn adapts a MutateFn to a Mutator, which reports no fields
+func FromMutateFn(f MutateFn) Mutator {
+	return func() ([]string, error) {
+		return nil, f()
+	}
+}
+
+// Chain runs the mutators in order, until one fails, and reports the fields
+// touched by all of them.
+func Chain(mutators ...Mutator) Mutator {
+	return func() ([]string, error) {
+		var touched []string
+		for _, m := range mutators {
+			fields, err := m()
+			touched = append(touched, fields...)
+			if err != nil {
+				return touched, err
+			}
+		}
+		return touched, nil
+	}
+}
+
+// When runs the mutator only if cond is true. The condition is evaluated on
+// each invocation, so it can depend on the existing object.
+func When(cond func() bool, m Mutator) Mutator {
+	return func() ([]string, error) {
+		if !cond() {
+			return nil, nil
+		}
+		return m()
+	}
+}
+
+// ForKind runs the mutator only if obj is of the given kind. The kind of
+// typed objects is looked up in the scheme, see NewClient.
+func ForKind(scheme *runtime.Scheme, obj Object, gk schema.GroupKind, m Mutator) Mutator {
+	return func() ([]string, error) {
+		gvk, err := gvkForObject(scheme, obj)
+		if err != nil {
+			return nil, err
+		}
+		if gvk.GroupKind() != gk {
+			return nil, nil
+		}
+		return m()
+	}
+}
+
+// SetLabels sets the given labels of obj, the other labels are kept
+func SetLabels(obj Object, labels map[string]string) Mutator {
+	return func() ([]string, error) {
+		merged, touched := mergeStringMap(obj.GetLabels(), labels, "/metadata/labels")
+		obj.SetLabels(merged)
+		return touched, nil
+	}
+}
+
+// MergeAnnotations sets the given annotations of obj, the other annotations
+// are kept
+func MergeAnnotations(obj Object, annotations map[string]string) Mutator {
+	return func() ([]string, error) {
+		merged, touched := mergeStringMap(obj.GetAnnotations(), annotations, "/metadata/annotations")
+		obj.SetAnnotations(merged)
+		return touched, nil
+	}
+}
+
+// SetOwner sets owner as the controller of obj, see SetControllerReference
+func SetOwner(owner, obj Object, scheme *runtime.Scheme) Mutator {
+	return func() ([]string, error) {
+		before := obj.GetOwnerReferences()
+		if err := SetControllerReference(owner, obj, scheme); err != nil {
+			return nil, err
+		}
+		if equality.Semantic.DeepEqual(before, obj.GetOwnerReferences()) {
+			return nil, nil
+		}
+		return []string{"/metadata/ownerReferences"}, nil
+	}
+}
+
+// SetImage sets the image of the container, or init container, with the
+// given name. obj is a Pod, a CronJob, or an object with a pod template like
+// a Deployment. It fails when there's no such container.
+func SetImage(obj Object, container, image string) Mutator {
+	return func() ([]string, error) {
+		return mutateContainer(obj, container, func(c map[string]interface{}, pointer string) ([]string, error) {
+			if c["image"] == image {
+				return nil, nil
+			}
+			c["image"] = image
+			return []string{appendPointer(pointer, "image")}, nil
+		})
+	}
+}
+
+// SetEnv sets the environment variables of the container, or init
+// container, with the given name, replacing the variables with the same
+// names. See SetImage for the kinds of obj.
+func SetEnv(obj Object, container string, env ...corev1.EnvVar) Mutator {
+	return func() ([]string, error) {
+		return mutateContainer(obj, container, func(c map[string]interface{}, pointer string) ([]string, error) {
+			vars, _, err := unstructured.NestedSlice(c, "env")
+			if err != nil {
+				return nil, err
+			}
+
+			var touched []string
+			for i := range env {
+				desired, err := unstructuredConverter.ToUnstructured(&env[i])
+				if err != nil {
+					return nil, err
+				}
+				index := len(vars)
+				for j, v := range vars {
+					if m, ok := v.(map[string]interface{}); ok && m["name"] == env[i].Name {
+						index = j
+						break
+					}
+				}
+				if index == len(vars) {
+					vars = append(vars, desired)
+				} else if equality.Semantic.DeepEqual(vars[index], desired) {
+					continue
+				} else {
+					vars[index] = desired
+				}
+				touched = append(touched, appendPointer(appendPointer(pointer, "env"), strconv.Itoa(index)))
+			}
+			if len(touched) == 0 {
+				return nil, nil
+			}
+			return touched, unstructured.SetNestedSlice(c, vars, "env")
+		})
+	}
+}
+
+// mergeStringMap sets the desired entries in current, it returns the merged
+// map and the sorted pointers of the changed entries.
+func mergeStringMap(current, desired map[string]string, pointer string) (map[string]string, []string) {
+	var touched []string
+	for k, v := range desired {
+		if existing, ok := current[k]; ok && existing == v {
+			continue
+		}
+		if current == nil {
+			current = make(map[string]string, len(desired))
+		}
+		current[k] = v
+		touched = append(touched, appendPointer(pointer, k))
+	}
+	sort.Strings(touched)
+	return current, touched
+}
+
+// mutateContainer calls f with the container with the given name of obj, and
+// its pointer. Typed objects are mutated through their unstructured
+// representation.
+func mutateContainer(obj Object, name string, f func(container map[string]interface{}, pointer string) ([]string, error)) ([]string, error) {
+	uns, err := unstructuredFromObject(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range podSpecPaths {
+		podSpec, found, err := unstructured.NestedMap(uns.Object, path...)
+		if err != nil || !found {
+			continue
+		}
+		if _, hasContainers := podSpec["containers"]; !hasContainers && len(path) == 1 {
+			continue
+		}
+		pointer := ""
+		for _, token := range path {
+			pointer = appendPointer(pointer, token)
+		}
+
+		for _, field := range []string{"containers", "initContainers"} {
+			containers, _, err := unstructured.NestedSlice(podSpec, field)
+			if err != nil {
+				return nil, err
+			}
+			for i, c := range containers {
+				container, ok := c.(map[string]interface{})
+				if !ok || container["name"] != name {
+					continue
+				}
+				touched, err := f(container, appendPointer(appendPointer(pointer, field), strconv.Itoa(i)))
+				if err != nil || len(touched) == 0 {
+					return nil, err
+				}
+				if err = unstructured.SetNestedSlice(uns.Object, containers, append(path, field)...); err != nil {
+					return nil, err
+				}
+				return touched, objectFromUnstructured(uns, obj)
+			}
+		}
+		break
+	}
+	return nil, fmt.Errorf("container %s not found in %s", name, namespacedNameFromObject(obj))
+}
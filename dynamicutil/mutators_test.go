@@ -0,0 +1,176 @@
+package dynamicutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("Mutators", func() {
+	var deploy *appsv1.Deployment
+
+	BeforeEach(func() {
+		deploy = &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", Labels: map[string]string{"keep": "me"}},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						InitContainers: []corev1.Container{{Name: "init", Image: "init:v1"}},
+						Containers: []corev1.Container{
+							{Name: "app", Image: "app:v1", Env: []corev1.EnvVar{{Name: "LEVEL", Value: "info"}}},
+							{Name: "proxy", Image: "proxy:v1"},
+						},
+					},
+				},
+			},
+		}
+	})
+
+	It("sets labels and annotations and reports the changed keys", func() {
+		touched, err := Chain(
+			SetLabels(deploy, map[string]string{"keep": "me", "app.kubernetes.io/name": "foo"}),
+			MergeAnnotations(deploy, map[string]string{"b": "2", "a": "1"}),
+		)()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(touched).To(Equal([]string{
+			"/metadata/labels/app.kubernetes.io~1name",
+			"/metadata/annotations/a",
+			"/metadata/annotations/b",
+		}))
+		Expect(deploy.Labels).To(Equal(map[string]string{"keep": "me", "app.kubernetes.io/name": "foo"}))
+		Expect(deploy.Annotations).To(Equal(map[string]string{"a": "1", "b": "2"}))
+	})
+
+	It("sets the images and environment of typed containers by name", func() {
+		touched, err := Chain(
+			SetImage(deploy, "proxy", "proxy:v2"),
+			SetImage(deploy, "init", "init:v1"),
+			SetEnv(deploy, "app", corev1.EnvVar{Name: "LEVEL", Value: "debug"}, corev1.EnvVar{Name: "MODE", Value: "dev"}),
+		)()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(touched).To(Equal([]string{
+			"/spec/template/spec/containers/1/image",
+			"/spec/template/spec/containers/0/env/0",
+			"/spec/template/spec/containers/0/env/1",
+		}))
+		Expect(deploy.Spec.Template.Spec.Containers[1].Image).To(Equal("proxy:v2"))
+		Expect(deploy.Spec.Template.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+			{Name: "LEVEL", Value: "debug"},
+			{Name: "MODE", Value: "dev"},
+		}))
+
+		By("reporting nothing when the containers are unchanged")
+		touched, err = SetEnv(deploy, "app", corev1.EnvVar{Name: "MODE", Value: "dev"})()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(touched).To(BeEmpty())
+	})
+
+	It("sets the images of unstructured pods and cron jobs", func() {
+		pod := &unstructured.Unstructured{}
+		pod.SetAPIVersion("v1")
+		pod.SetKind("Pod")
+		pod.SetName("foo")
+		Expect(unstructured.SetNestedSlice(pod.Object, []interface{}{
+			map[string]interface{}{"name": "app", "image": "app:v1"},
+		}, "spec", "containers")).To(Succeed())
+
+		touched, err := SetImage(pod, "app", "app:v2")()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(touched).To(Equal([]string{"/spec/containers/0/image"}))
+		containers, _, err := unstructured.NestedSlice(pod.Object, "spec", "containers")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(containers).To(Equal([]interface{}{map[string]interface{}{"name": "app", "image": "app:v2"}}))
+
+		cronJob := &unstructured.Unstructured{}
+		cronJob.SetAPIVersion("batch/v1beta1")
+		cronJob.SetKind("CronJob")
+		cronJob.SetName("foo")
+		Expect(unstructured.SetNestedSlice(cronJob.Object, []interface{}{
+			map[string]interface{}{"name": "job", "image": "job:v1"},
+		}, "spec", "jobTemplate", "spec", "template", "spec", "containers")).To(Succeed())
+
+		touched, err = SetImage(cronJob, "job", "job:v2")()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(touched).To(Equal([]string{"/spec/jobTemplate/spec/template/spec/containers/0/image"}))
+	})
+
+	It("fails when the container doesn't exist", func() {
+		_, err := SetImage(deploy, "missing", "missing:v1")()
+		Expect(err).To(MatchError("container missing not found in default/foo"))
+	})
+
+	It("sets the owner", func() {
+		owner := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "owner", Namespace: "default", UID: "owner-uid"}}
+		setOwner := SetOwner(owner, deploy, clientgoscheme.Scheme)
+
+		touched, err := setOwner()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(touched).To(Equal([]string{"/metadata/ownerReferences"}))
+		Expect(metav1.IsControlledBy(deploy, owner)).To(BeTrue())
+
+		touched, err = setOwner()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(touched).To(BeEmpty())
+	})
+
+	It("runs the mutators conditionally", func() {
+		setImage := SetImage(deploy, "app", "app:v2")
+
+		touched, err := Chain(
+			When(func() bool { return deploy.Spec.Replicas != nil }, setImage),
+			ForKind(clientgoscheme.Scheme, deploy, schema.GroupKind{Kind: "ConfigMap"}, setImage),
+		)()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(touched).To(BeEmpty())
+		Expect(deploy.Spec.Template.Spec.Containers[0].Image).To(Equal("app:v1"))
+
+		touched, err = ForKind(clientgoscheme.Scheme, deploy, schema.GroupKind{Group: "apps", Kind: "Deployment"}, setImage)()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(touched).To(Equal([]string{"/spec/template/spec/containers/0/image"}))
+	})
+
+	It("stops the chain at the first error", func() {
+		failed := errors.New("failed")
+		touched, err := Chain(
+			SetLabels(deploy, map[string]string{"foo": "bar"}),
+			FromMutateFn(func() error { return failed }),
+			SetLabels(deploy, map[string]string{"bar": "baz"}),
+		)()
+		Expect(err).To(Equal(failed))
+		Expect(touched).To(Equal([]string{"/metadata/labels/foo"}))
+		Expect(deploy.Labels).NotTo(HaveKey("bar"))
+	})
+
+	It("mutates objects in CreateOrUpdate", func() {
+		cm := &unstructured.Unstructured{}
+		cm.SetAPIVersion("v1")
+		cm.SetKind("ConfigMap")
+		cm.SetName(fmt.Sprintf("cm-%d", rand.Int31()))
+		cm.SetNamespace("default")
+		configMapCli := dynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+
+		var touched []string
+		mutate := Chain(
+			SetLabels(cm, map[string]string{"app": "foo"}),
+			MergeAnnotations(cm, map[string]string{"owner": "team"}),
+		)
+		op, err := CreateOrUpdate(context.TODO(), configMapCli, cm, mutate.MutateFn(&touched))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultCreated))
+		Expect(touched).To(Equal([]string{"/metadata/labels/app", "/metadata/annotations/owner"}))
+
+		op, err = CreateOrUpdate(context.TODO(), configMapCli, cm, mutate.MutateFn(&touched))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+		Expect(touched).To(BeEmpty())
+	})
+})
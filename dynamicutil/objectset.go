@@ -0,0 +1,167 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// kindOrder is the order in which the objects of an object set are applied,
+// by kind, like the install order of Helm. The kinds which aren't listed,
+// custom resources mostly, are applied after them, webhook configurations
+// excepted: they're applied last so they don't intercept the creation of
+// the objects they depend on.
+var kindOrder = []string{
+	"Namespace",
+	"CustomResourceDefinition",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"PriorityClass",
+	"ServiceAccount",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"Ingress",
+	"APIService",
+}
+
+// lastKinds are applied after all the other kinds
+var lastKinds = []string{
+	"MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration",
+}
+
+// ObjectSetOptions contains the options of ApplyObjectSet
+type ObjectSetOptions struct {
+	// Workers is the maximum number of objects applied concurrently, objects
+	// of different kinds are never applied concurrently. Defaults to 1.
+	Workers int
+	// Options are the options of the CreateOrUpdate calls
+	Options []Option
+}
+
+// ApplyObjectSet applies the objects like ApplyManifests, ordered by kind so
+// the objects are created after the ones they depend on: Namespaces first,
+// then CustomResourceDefinitions, ServiceAccounts, RBAC, ConfigMaps and
+// Secrets, workloads, custom resources, and webhook configurations last.
+//
+// The objects of the same kind are applied concurrently. Once the
+// CustomResourceDefinitions are applied, they are waited for to be
+// established, and the RESTMapper of the Client is reset, if it supports it,
+// so the new kinds are resolved. Dry-runs don't wait for them.
+//
+// The results are in the order of the objects.
+func ApplyObjectSet(ctx context.Context, c *Client, objects []*unstructured.Unstructured, opts ObjectSetOptions) ([]ManifestResult, error) {
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	dryRun := newOptions(opts.Options).DryRun != DryRunNone
+
+	results := make([]ManifestResult, len(objects))
+	var errs []error
+	for _, group := range groupByKindOrder(objects) {
+		applyConcurrently(ctx, c, objects, group, results, workers, opts.Options)
+
+		if dryRun || objects[group[0]].GetKind() != "CustomResourceDefinition" {
+			continue
+		}
+		var crds []Object
+		for _, i := range group {
+			if results[i].Err == nil {
+				crds = append(crds, results[i].Object)
+			}
+		}
+		if err := WaitReady(ctx, c, crds...); err != nil {
+			errs = append(errs, fmt.Errorf("waiting for the custom resource definitions: %w", err))
+		}
+		if mapper, ok := c.mapper.(resettableRESTMapper); ok {
+			mapper.Reset()
+		}
+	}
+
+	if err := manifestErrors(results); err != nil {
+		errs = append(errs, err)
+	}
+	return results, utilerrors.Flatten(utilerrors.NewAggregate(errs))
+}
+
+// applyConcurrently applies the objects at the given indexes with at most
+// workers concurrent calls, and stores their results at the same indexes.
+func applyConcurrently(ctx context.Context, c *Client, objects []*unstructured.Unstructured, indexes []int, results []ManifestResult, workers int, opts []Option) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for _, i := range indexes {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = applyManifest(ctx, c, objects[i], opts)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// groupByKindOrder returns the indexes of the objects grouped by kind, in
+// the order the groups must be applied. The objects keep their order in
+// their group.
+func groupByKindOrder(objects []*unstructured.Unstructured) [][]int {
+	priorities := make(map[string]int, len(kindOrder)+len(lastKinds))
+	for i, kind := range kindOrder {
+		priorities[kind] = i
+	}
+	for i, kind := range lastKinds {
+		priorities[kind] = len(kindOrder) + 1 + i
+	}
+	priority := func(kind string) int {
+		if p, ok := priorities[kind]; ok {
+			return p
+		}
+		return len(kindOrder)
+	}
+
+	indexes := make([]int, len(objects))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		ki, kj := objects[indexes[i]].GetKind(), objects[indexes[j]].GetKind()
+		if pi, pj := priority(ki), priority(kj); pi != pj {
+			return pi < pj
+		}
+		return ki < kj
+	})
+
+	var groups [][]int
+	for i, index := range indexes {
+		if i == 0 || objects[index].GetKind() != objects[indexes[i-1]].GetKind() {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], index)
+	}
+	return groups
+}
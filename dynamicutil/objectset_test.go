@@ -0,0 +1,227 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/gomega"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("ApplyObjectSet", func() {
+	var mapper *meta.DefaultRESTMapper
+	var suffix int32
+
+	BeforeEach(func() {
+		mapper = meta.NewDefaultRESTMapper(nil)
+		mapper.Add(corev1.SchemeGroupVersion.WithKind("Namespace"), meta.RESTScopeRoot)
+		mapper.Add(corev1.SchemeGroupVersion.WithKind("ServiceAccount"), meta.RESTScopeNamespace)
+		mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+		mapper.Add(rbacv1.SchemeGroupVersion.WithKind("Role"), meta.RESTScopeNamespace)
+		mapper.Add(admissionregistrationv1.SchemeGroupVersion.WithKind("ValidatingWebhookConfiguration"), meta.RESTScopeRoot)
+		mapper.Add(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}, meta.RESTScopeRoot)
+		suffix = rand.Int31()
+	})
+
+	decode := func(manifest string) []*unstructured.Unstructured {
+		objects, err := DecodeManifests(strings.NewReader(strings.ReplaceAll(manifest, "SUFFIX", fmt.Sprint(suffix))))
+		Expect(err).NotTo(HaveOccurred())
+		return objects
+	}
+
+	It("applies the objects ordered by kind", func() {
+		recorder := &creationRecorder{Interface: dynClient}
+		c := NewClient(recorder, mapper, clientgoscheme.Scheme)
+		objects := decode(`
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: webhook-SUFFIX
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm-a
+  namespace: ns-SUFFIX
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: role-SUFFIX
+  namespace: ns-SUFFIX
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm-b
+  namespace: ns-SUFFIX
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: sa-SUFFIX
+  namespace: ns-SUFFIX
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: ns-SUFFIX
+`)
+
+		results, err := ApplyObjectSet(context.TODO(), c, objects, ObjectSetOptions{Workers: 4})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(HaveLen(len(objects)))
+		for i, result := range results {
+			Expect(result.Result).To(BeEquivalentTo(OperationResultCreated))
+			Expect(result.Object.GetName()).To(Equal(objects[i].GetName()))
+		}
+
+		created := recorder.kinds()
+		Expect(created).To(HaveLen(len(objects)))
+		Expect(created[0]).To(Equal("Namespace"))
+		Expect(created[1]).To(Equal("ServiceAccount"))
+		Expect(created[2:4]).To(Equal([]string{"ConfigMap", "ConfigMap"}))
+		Expect(created[4]).To(Equal("Role"))
+		Expect(created[5]).To(Equal("ValidatingWebhookConfiguration"))
+	})
+
+	It("waits for the custom resource definitions before applying custom resources", func() {
+		widgetGVK := schema.GroupVersionKind{Group: fmt.Sprintf("example-%d.com", suffix), Version: "v1", Kind: "Widget"}
+		resetting := &resettingRESTMapper{DefaultRESTMapper: mapper, onReset: func() {
+			mapper.Add(widgetGVK, meta.RESTScopeNamespace)
+		}}
+		c := NewClient(dynClient, resetting, clientgoscheme.Scheme)
+		objects := decode(`
+apiVersion: example-SUFFIX.com/v1
+kind: Widget
+metadata:
+  name: foo
+  namespace: default
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example-SUFFIX.com
+spec:
+  group: example-SUFFIX.com
+  names:
+    kind: Widget
+    listKind: WidgetList
+    plural: widgets
+    singular: widget
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+`)
+
+		By("establishing the custom resource definition once created")
+		crdCli := dynClient.Resource(schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"})
+		crdName := "widgets." + widgetGVK.Group
+		established := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(established)
+			Eventually(func() error {
+				_, err := crdCli.Get(context.TODO(), crdName, metav1.GetOptions{})
+				return err
+			}, 10*time.Second, 10*time.Millisecond).Should(Succeed())
+			patch := []byte(`{"status":{"conditions":[{"type":"Established","status":"True","reason":"InitialNamesAccepted","lastTransitionTime":"2020-01-01T00:00:00Z"}]}}`)
+			_, err := crdCli.Patch(context.TODO(), crdName, types.MergePatchType, patch, metav1.PatchOptions{}, "status")
+			Expect(err).NotTo(HaveOccurred())
+		}()
+
+		results, err := ApplyObjectSet(context.TODO(), c, objects, ObjectSetOptions{})
+		<-established
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results[0].Result).To(BeEquivalentTo(OperationResultCreated))
+		Expect(results[1].Result).To(BeEquivalentTo(OperationResultCreated))
+		Expect(resetting.resets).To(BeNumerically(">=", 1))
+	})
+
+	It("reports the objects which failed", func() {
+		c := NewClient(dynClient, mapper, clientgoscheme.Scheme)
+		objects := decode(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm-SUFFIX
+  namespace: default
+---
+apiVersion: example.com/v1
+kind: Gizmo
+metadata:
+  name: gizmo-SUFFIX
+  namespace: default
+`)
+
+		results, err := ApplyObjectSet(context.TODO(), c, objects, ObjectSetOptions{Workers: 2})
+		Expect(err).To(MatchError(ContainSubstring("Gizmo default/gizmo-")))
+		Expect(results[0].Err).NotTo(HaveOccurred())
+		Expect(results[1].Err).To(HaveOccurred())
+	})
+})
+
+// creationRecorder records the kinds of the created objects
+type creationRecorder struct {
+	dynamic.Interface
+	mu      sync.Mutex
+	created []string
+}
+
+func (r *creationRecorder) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &namespaceableCreationRecorder{NamespaceableResourceInterface: r.Interface.Resource(resource), recorder: r}
+}
+
+func (r *creationRecorder) record(obj *unstructured.Unstructured) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.created = append(r.created, obj.GetKind())
+}
+
+func (r *creationRecorder) kinds() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.created...)
+}
+
+type namespaceableCreationRecorder struct {
+	dynamic.NamespaceableResourceInterface
+	recorder *creationRecorder
+}
+
+func (r *namespaceableCreationRecorder) Namespace(ns string) dynamic.ResourceInterface {
+	return &resourceCreationRecorder{ResourceInterface: r.NamespaceableResourceInterface.Namespace(ns), recorder: r.recorder}
+}
+
+func (r *namespaceableCreationRecorder) Create(ctx context.Context, obj *unstructured.Unstructured, options metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	r.recorder.record(obj)
+	return r.NamespaceableResourceInterface.Create(ctx, obj, options, subresources...)
+}
+
+type resourceCreationRecorder struct {
+	dynamic.ResourceInterface
+	recorder *creationRecorder
+}
+
+func (r *resourceCreationRecorder) Create(ctx context.Context, obj *unstructured.Unstructured, options metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	r.recorder.record(obj)
+	return r.ResourceInterface.Create(ctx, obj, options, subresources...)
+}
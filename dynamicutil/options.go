@@ -0,0 +1,77 @@
+package dynamicutil
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Options contains the options of CreateOrUpdate and CreateOrPatch
+type Options struct {
+	// Backoff enables retrying the whole operation, the MutateFn included,
+	// on conflicts and transient errors. Nil disables retrying.
+	Backoff *wait.Backoff
+	// Scheme is used to set the apiVersion and kind of typed objects sent to
+	// the API server. Nil leaves them as they are.
+	Scheme *runtime.Scheme
+	// RedactSecrets hides the values of Secrets in change reports.
+	RedactSecrets bool
+	// Defaulter is applied to a copy of the mutated object before comparing
+	// it with the existing object. Nil compares the object as it is.
+	Defaulter DefaultingFunc
+	// IgnoreDifferences are the rules of the fields excluded from the
+	// comparison and kept at their live values.
+	IgnoreDifferences []IgnoreDifference
+	// DryRun previews the operation without persisting it, see DryRunMode.
+	DryRun DryRunMode
+	// RecreateOnImmutableFields deletes and creates the object again when
+	// the update changes immutable fields.
+	RecreateOnImmutableFields bool
+	// Manager is set as the ManagedByLabel of the objects, the existing
+	// objects with another manager are handled according to the
+	// OwnershipPolicy. Empty disables the ownership guard.
+	Manager string
+	// OwnershipPolicy tells what to do with the objects with another manager
+	OwnershipPolicy OwnershipPolicy
+
+	report *ChangeReport
+}
+
+// Option configures the Options of CreateOrUpdate and CreateOrPatch
+type Option func(*Options)
+
+// WithRetry retries the operation with the given backoff when it fails with
+// a conflict, an already exists error from racing creations, or a transient
+// error (429, 5xx, timeouts and connection resets). The object is fetched
+// again and the MutateFn is re-invoked on it before each retry. A backoff
+// with less than one step makes a single attempt.
+func WithRetry(backoff wait.Backoff) Option {
+	return func(o *Options) {
+		o.Backoff = &backoff
+	}
+}
+
+// WithScheme sets the apiVersion and kind of typed objects from the scheme
+// before they are sent to the API server, so TypeMeta can be left empty.
+// Objects whose type is not registered in the scheme, or registered as
+// several kinds, are rejected.
+func WithScheme(scheme *runtime.Scheme) Option {
+	return func(o *Options) {
+		o.Scheme = scheme
+	}
+}
+
+// WithSecretRedaction hides the values of the data and stringData fields of
+// Secrets in change reports, only the changed keys are reported.
+func WithSecretRedaction() Option {
+	return func(o *Options) {
+		o.RedactSecrets = true
+	}
+}
+
+func newOptions(opts []Option) *Options {
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -0,0 +1,75 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("WithScheme", func() {
+	var configMapCli dynamic.NamespaceableResourceInterface
+	var cm *corev1.ConfigMap
+	var cmKey string
+
+	BeforeEach(func() {
+		configMapCli = dynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+
+		cmKey = fmt.Sprintf("cm-%d", rand.Int31())
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      cmKey,
+				Namespace: "default",
+			},
+		}
+	})
+
+	It("stamps apiVersion and kind on typed objects", func() {
+		op, err := CreateOrUpdate(context.TODO(), configMapCli, cm, deploymentIdentity, WithScheme(clientgoscheme.Scheme))
+
+		By("returning no error")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("returning OperationResultCreated")
+		Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+		By("having apiVersion and kind set on the created object")
+		fetchedUns, err := configMapCli.Namespace("default").Get(context.TODO(), cmKey, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetchedUns.GetAPIVersion()).To(Equal("v1"))
+		Expect(fetchedUns.GetKind()).To(Equal("ConfigMap"))
+	})
+
+	It("rejects objects not registered in the scheme", func() {
+		op, err := CreateOrUpdate(context.TODO(), configMapCli, cm, deploymentIdentity, WithScheme(runtime.NewScheme()))
+
+		Expect(err).To(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+	})
+
+	It("rejects objects with an ambiguous kind", func() {
+		scheme := runtime.NewScheme()
+		scheme.AddKnownTypes(corev1.SchemeGroupVersion, &corev1.ConfigMap{})
+		scheme.AddKnownTypeWithName(corev1.SchemeGroupVersion.WithKind("LegacyConfigMap"), &corev1.ConfigMap{})
+		op, err := CreateOrUpdate(context.TODO(), configMapCli, cm, deploymentIdentity, WithScheme(scheme))
+
+		Expect(err).To(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+	})
+
+	It("rejects objects whose TypeMeta doesn't match the scheme", func() {
+		cm.TypeMeta = metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "Deployment"}
+		op, err := CreateOrUpdate(context.TODO(), configMapCli, cm, deploymentIdentity, WithScheme(clientgoscheme.Scheme))
+
+		Expect(err).To(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+	})
+})
@@ -0,0 +1,158 @@
+package dynamicutil
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// AlreadyOwnedError is returned by SetControllerReference when the object
+// already has a different controller
+type AlreadyOwnedError struct {
+	Object metav1.Object
+	Owner  metav1.OwnerReference
+}
+
+func (e *AlreadyOwnedError) Error() string {
+	return fmt.Sprintf("object %s is already owned by another %s controller %s", namespacedNameFromObject(e.Object), e.Owner.Kind, e.Owner.Name)
+}
+
+// SetControllerReference sets owner as the controller of object, so object
+// is garbage collected when owner is deleted and owner is reconciled when
+// object changes. Both may be typed or unstructured objects, see
+// SetOwnerReference for the kind of owner.
+//
+// An AlreadyOwnedError is returned when object already has another
+// controller.
+func SetControllerReference(owner, object Object, scheme *runtime.Scheme) error {
+	ref, err := ownerReference(owner, object, scheme)
+	if err != nil {
+		return err
+	}
+	isController := true
+	blockOwnerDeletion := true
+	ref.Controller = &isController
+	ref.BlockOwnerDeletion = &blockOwnerDeletion
+
+	if existing := metav1.GetControllerOf(object); existing != nil && !referSameObject(*existing, ref) {
+		return &AlreadyOwnedError{Object: object, Owner: *existing}
+	}
+	upsertOwnerReference(object, ref)
+	return nil
+}
+
+// SetOwnerReference adds owner to the owners of object, an existing
+// reference to owner is updated. The kind of owner is looked up in the
+// scheme, or taken from its apiVersion and kind if it's unstructured or the
+// scheme is nil.
+//
+// The owner must be cluster-scoped, or in the namespace of object.
+func SetOwnerReference(owner, object Object, scheme *runtime.Scheme) error {
+	ref, err := ownerReference(owner, object, scheme)
+	if err != nil {
+		return err
+	}
+	// The controller and blockOwnerDeletion flags of an existing reference
+	// are kept.
+	for _, existing := range object.GetOwnerReferences() {
+		if referSameObject(existing, ref) {
+			ref.Controller = existing.Controller
+			ref.BlockOwnerDeletion = existing.BlockOwnerDeletion
+		}
+	}
+	upsertOwnerReference(object, ref)
+	return nil
+}
+
+// RemoveOwnerReference removes owner from the owners of object, it's a no-op
+// if owner isn't one of them.
+func RemoveOwnerReference(owner, object Object, scheme *runtime.Scheme) error {
+	gvk, err := gvkForObject(scheme, owner)
+	if err != nil {
+		return err
+	}
+	ref := metav1.OwnerReference{APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind, Name: owner.GetName()}
+
+	existing := object.GetOwnerReferences()
+	refs := make([]metav1.OwnerReference, 0, len(existing))
+	for _, r := range existing {
+		if !referSameObject(r, ref) {
+			refs = append(refs, r)
+		}
+	}
+	if len(refs) != len(existing) {
+		object.SetOwnerReferences(refs)
+	}
+	return nil
+}
+
+// HasControllerReference tells whether object has a controller
+func HasControllerReference(object Object) bool {
+	return metav1.GetControllerOf(object) != nil
+}
+
+// ownerReference returns the reference of owner, after checking it can own
+// object.
+func ownerReference(owner, object Object, scheme *runtime.Scheme) (metav1.OwnerReference, error) {
+	if err := validateOwner(owner, object); err != nil {
+		return metav1.OwnerReference{}, err
+	}
+	gvk, err := gvkForObject(scheme, owner)
+	if err != nil {
+		return metav1.OwnerReference{}, err
+	}
+	return metav1.OwnerReference{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Name:       owner.GetName(),
+		UID:        owner.GetUID(),
+	}, nil
+}
+
+// validateOwner checks that owner is cluster-scoped or in the namespace of
+// object, namespaced objects can't own cluster-scoped objects or objects in
+// other namespaces.
+func validateOwner(owner, object Object) error {
+	ownerNs := owner.GetNamespace()
+	if ownerNs == "" {
+		return nil
+	}
+	objectNs := object.GetNamespace()
+	if objectNs == "" {
+		return fmt.Errorf("cluster-scoped object %s cannot have namespaced owner %s", object.GetName(), namespacedNameFromObject(owner))
+	}
+	if ownerNs != objectNs {
+		return fmt.Errorf("object %s cannot have owner %s in another namespace", namespacedNameFromObject(object), namespacedNameFromObject(owner))
+	}
+	return nil
+}
+
+// upsertOwnerReference replaces the reference of object to the same owner
+// as ref, or adds ref.
+func upsertOwnerReference(object Object, ref metav1.OwnerReference) {
+	refs := object.GetOwnerReferences()
+	for i := range refs {
+		if referSameObject(refs[i], ref) {
+			refs[i] = ref
+			object.SetOwnerReferences(refs)
+			return
+		}
+	}
+	object.SetOwnerReferences(append(refs, ref))
+}
+
+// referSameObject tells whether both references point to the same object,
+// the version of their group is ignored.
+func referSameObject(a, b metav1.OwnerReference) bool {
+	aGV, err := schema.ParseGroupVersion(a.APIVersion)
+	if err != nil {
+		return false
+	}
+	bGV, err := schema.ParseGroupVersion(b.APIVersion)
+	if err != nil {
+		return false
+	}
+	return aGV.Group == bGV.Group && a.Kind == b.Kind && a.Name == b.Name
+}
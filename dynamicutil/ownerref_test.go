@@ -0,0 +1,108 @@
+package dynamicutil
+
+import (
+	"errors"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("OwnerReferences", func() {
+	var owner *appsv1.Deployment
+	var object *unstructured.Unstructured
+
+	BeforeEach(func() {
+		owner = &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "owner", Namespace: "default", UID: "owner-uid"},
+		}
+
+		object = &unstructured.Unstructured{}
+		object.SetAPIVersion("v1")
+		object.SetKind("ConfigMap")
+		object.SetName("object")
+		object.SetNamespace("default")
+	})
+
+	It("sets the controller reference of unstructured objects", func() {
+		Expect(SetControllerReference(owner, object, clientgoscheme.Scheme)).To(Succeed())
+
+		isTrue := true
+		Expect(object.GetOwnerReferences()).To(ConsistOf(metav1.OwnerReference{
+			APIVersion:         "apps/v1",
+			Kind:               "Deployment",
+			Name:               "owner",
+			UID:                "owner-uid",
+			Controller:         &isTrue,
+			BlockOwnerDeletion: &isTrue,
+		}))
+		Expect(HasControllerReference(object)).To(BeTrue())
+
+		By("being idempotent")
+		Expect(SetControllerReference(owner, object, clientgoscheme.Scheme)).To(Succeed())
+		Expect(object.GetOwnerReferences()).To(HaveLen(1))
+	})
+
+	It("sets the references of typed objects to unstructured owners", func() {
+		typed := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "typed", Namespace: "default"}}
+		Expect(SetOwnerReference(object, typed, nil)).To(Succeed())
+
+		Expect(typed.OwnerReferences).To(ConsistOf(metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "object"}))
+		Expect(HasControllerReference(typed)).To(BeFalse())
+	})
+
+	It("refuses another controller", func() {
+		Expect(SetControllerReference(owner, object, clientgoscheme.Scheme)).To(Succeed())
+
+		other := owner.DeepCopy()
+		other.Name = "other"
+		err := SetControllerReference(other, object, clientgoscheme.Scheme)
+		var alreadyOwned *AlreadyOwnedError
+		Expect(errors.As(err, &alreadyOwned)).To(BeTrue())
+		Expect(alreadyOwned.Owner.Name).To(Equal("owner"))
+
+		By("allowing other owners")
+		Expect(SetOwnerReference(other, object, clientgoscheme.Scheme)).To(Succeed())
+		Expect(object.GetOwnerReferences()).To(HaveLen(2))
+	})
+
+	It("keeps the controller flag of an existing reference", func() {
+		Expect(SetControllerReference(owner, object, clientgoscheme.Scheme)).To(Succeed())
+		Expect(SetOwnerReference(owner, object, clientgoscheme.Scheme)).To(Succeed())
+		Expect(HasControllerReference(object)).To(BeTrue())
+	})
+
+	It("removes owner references", func() {
+		Expect(SetControllerReference(owner, object, clientgoscheme.Scheme)).To(Succeed())
+
+		Expect(RemoveOwnerReference(owner, object, clientgoscheme.Scheme)).To(Succeed())
+		Expect(object.GetOwnerReferences()).To(BeEmpty())
+		Expect(HasControllerReference(object)).To(BeFalse())
+
+		By("ignoring owners which aren't referenced")
+		Expect(RemoveOwnerReference(owner, object, clientgoscheme.Scheme)).To(Succeed())
+	})
+
+	It("validates the scope of the owner", func() {
+		By("refusing owners in another namespace")
+		owner.Namespace = "other"
+		Expect(SetOwnerReference(owner, object, clientgoscheme.Scheme)).NotTo(Succeed())
+
+		By("refusing namespaced owners of cluster-scoped objects")
+		object.SetNamespace("")
+		Expect(SetControllerReference(owner, object, clientgoscheme.Scheme)).NotTo(Succeed())
+
+		By("allowing cluster-scoped owners")
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+		Expect(SetOwnerReference(node, object, clientgoscheme.Scheme)).To(Succeed())
+		object.SetNamespace("default")
+		Expect(SetOwnerReference(node, object, clientgoscheme.Scheme)).To(Succeed())
+	})
+
+	It("requires the kind of the owner", func() {
+		Expect(SetOwnerReference(owner, object, nil)).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,108 @@
+package dynamicutil
+
+import (
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ManagedByLabel is the label naming the manager of an object
+const ManagedByLabel = "app.kubernetes.io/managed-by"
+
+// OwnershipPolicy tells what to do with existing objects which aren't
+// managed by the manager given to WithOwnership
+type OwnershipPolicy string
+
+const (
+	// OwnershipRefuse refuses to modify the objects not managed by the manager
+	OwnershipRefuse OwnershipPolicy = "refuse"
+	// OwnershipAdopt takes over the objects not managed by the manager
+	OwnershipAdopt OwnershipPolicy = "adopt"
+	// OwnershipAdoptUnmanaged takes over the objects without a manager, and
+	// refuses to modify the objects managed by another manager
+	OwnershipAdoptUnmanaged OwnershipPolicy = "adoptUnmanaged"
+)
+
+// ErrNotOwned is matched by errors.Is for the NotOwnedError returned when an
+// object isn't modified because it's not managed by the manager.
+var ErrNotOwned = errors.New("object is not owned")
+
+// NotOwnedError is returned when an existing object isn't modified because
+// it's not managed by the manager given to WithOwnership
+type NotOwnedError struct {
+	// Name is the name of the object
+	Name types.NamespacedName
+	// Manager is the manager of the object, empty if it has none
+	Manager string
+	// Expected is the manager given to WithOwnership
+	Expected string
+}
+
+func (e *NotOwnedError) Error() string {
+	if e.Manager == "" {
+		return fmt.Sprintf("%s is not managed by %s", e.Name, e.Expected)
+	}
+	return fmt.Sprintf("%s is managed by %s, not by %s", e.Name, e.Manager, e.Expected)
+}
+
+// Is makes errors.Is match ErrNotOwned
+func (e *NotOwnedError) Is(target error) bool {
+	return target == ErrNotOwned
+}
+
+// WithOwnership guards the objects managed by manager: the ManagedByLabel
+// is set to manager on the objects, and existing objects with another value
+// are handled according to policy. The MutateFn isn't called on the objects
+// which are refused.
+//
+// The update of an adopted object returns OperationResultAdopted.
+func WithOwnership(manager string, policy OwnershipPolicy) Option {
+	return func(o *Options) {
+		o.Manager = manager
+		o.OwnershipPolicy = policy
+	}
+}
+
+// checkOwnership tells whether the existing obj must be adopted, or returns
+// a NotOwnedError if it must not be modified.
+func (o *Options) checkOwnership(obj metav1.Object) (adopt bool, err error) {
+	if o.Manager == "" {
+		return false, nil
+	}
+
+	manager := obj.GetLabels()[ManagedByLabel]
+	switch {
+	case manager == o.Manager:
+		return false, nil
+	case o.OwnershipPolicy == OwnershipAdopt:
+		return true, nil
+	case o.OwnershipPolicy == OwnershipAdoptUnmanaged && manager == "":
+		return true, nil
+	default:
+		return false, &NotOwnedError{Name: namespacedNameFromObject(obj), Manager: manager, Expected: o.Manager}
+	}
+}
+
+// stampManager sets the ManagedByLabel of obj to the manager, if any
+func (o *Options) stampManager(obj metav1.Object) {
+	if o.Manager == "" {
+		return
+	}
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[ManagedByLabel] = o.Manager
+	obj.SetLabels(labels)
+}
+
+// adoptedResult returns OperationResultAdopted for the update of an adopted
+// object, and result otherwise.
+func adoptedResult(result OperationResult, adopted bool) OperationResult {
+	if adopted && result != OperationResultNone {
+		return OperationResultAdopted
+	}
+	return result
+}
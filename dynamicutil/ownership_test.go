@@ -0,0 +1,121 @@
+package dynamicutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var _ = Describe("Ownership", func() {
+	var configMapCli dynamic.NamespaceableResourceInterface
+	var cm *corev1.ConfigMap
+
+	BeforeEach(func() {
+		configMapCli = dynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+
+		cm = &corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("cm-%d", rand.Int31()),
+				Namespace: "default",
+			},
+		}
+	})
+
+	createManagedBy := func(manager string) {
+		existing := cm.DeepCopy()
+		_, err := CreateOrUpdate(context.TODO(), configMapCli, existing, func() error {
+			if manager != "" {
+				existing.Labels = map[string]string{ManagedByLabel: manager}
+			}
+			existing.Data = map[string]string{"foo": "bar"}
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	fetchConfigMap := func() *corev1.ConfigMap {
+		fetchedUns, err := configMapCli.Namespace(cm.Namespace).Get(context.TODO(), cm.Name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		fetched := &corev1.ConfigMap{}
+		Expect(unstructuredConverter.FromUnstructured(fetchedUns.Object, fetched)).To(Succeed())
+		return fetched
+	}
+
+	It("sets the manager label on creation", func() {
+		op, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"), WithOwnership("me", OwnershipRefuse))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultCreated))
+		Expect(fetchConfigMap().Labels).To(HaveKeyWithValue(ManagedByLabel, "me"))
+	})
+
+	It("updates the objects managed by the manager", func() {
+		createManagedBy("me")
+
+		op, err := CreateOrPatch(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "baz"), WithOwnership("me", OwnershipRefuse))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+
+		By("keeping the manager label removed by the MutateFn")
+		op, err = CreateOrUpdate(context.TODO(), configMapCli, cm, func() error {
+			cm.Labels = nil
+			return nil
+		}, WithOwnership("me", OwnershipRefuse))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+	})
+
+	It("refuses to update the objects managed by another manager", func() {
+		createManagedBy("other")
+
+		called := false
+		_, err := CreateOrUpdate(context.TODO(), configMapCli, cm, func() error {
+			called = true
+			return nil
+		}, WithOwnership("me", OwnershipAdoptUnmanaged))
+		Expect(errors.Is(err, ErrNotOwned)).To(BeTrue())
+		var notOwned *NotOwnedError
+		Expect(errors.As(err, &notOwned)).To(BeTrue())
+		Expect(notOwned.Manager).To(Equal("other"))
+
+		By("not calling the MutateFn")
+		Expect(called).To(BeFalse())
+		Expect(fetchConfigMap().Labels).To(HaveKeyWithValue(ManagedByLabel, "other"))
+	})
+
+	It("refuses to update the objects without a manager", func() {
+		createManagedBy("")
+
+		_, err := CreateOrPatch(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "baz"), WithOwnership("me", OwnershipRefuse))
+		Expect(errors.Is(err, ErrNotOwned)).To(BeTrue())
+		Expect(fetchConfigMap().Data).To(Equal(map[string]string{"foo": "bar"}))
+	})
+
+	It("adopts the objects without a manager", func() {
+		createManagedBy("")
+
+		op, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"), WithOwnership("me", OwnershipAdoptUnmanaged))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultAdopted))
+		Expect(fetchConfigMap().Labels).To(HaveKeyWithValue(ManagedByLabel, "me"))
+	})
+
+	It("adopts the objects managed by another manager", func() {
+		createManagedBy("other")
+
+		op, err := CreateOrPatch(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "baz"), WithOwnership("me", OwnershipAdopt))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultAdopted))
+
+		fetched := fetchConfigMap()
+		Expect(fetched.Labels).To(HaveKeyWithValue(ManagedByLabel, "me"))
+		Expect(fetched.Data).To(Equal(map[string]string{"foo": "baz"}))
+	})
+})
@@ -0,0 +1,112 @@
+package dynamicutil
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// WithRecreateOnImmutableFields deletes and creates the object again when the
+// API server rejects the update because it changes immutable fields, like
+// the template of a Job or the selector of a Deployment. The existing object
+// is deleted with foreground propagation, and created once it's gone.
+//
+// The object is unavailable in between, and its dependents are deleted with
+// it, so this is only suitable for objects which can be recreated safely.
+func WithRecreateOnImmutableFields() Option {
+	return func(o *Options) {
+		o.RecreateOnImmutableFields = true
+	}
+}
+
+// isImmutableFieldError tells whether err is an Invalid error caused by the
+// change of an immutable field.
+func isImmutableFieldError(err error) bool {
+	if !apierrors.IsInvalid(err) {
+		return false
+	}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) && status.Status().Details != nil {
+		for _, cause := range status.Status().Details.Causes {
+			if strings.Contains(cause.Message, "immutable") {
+				return true
+			}
+		}
+	}
+	return strings.Contains(err.Error(), "immutable")
+}
+
+// recreate deletes the existing object, waits for it to be gone, then creates
+// desired in its place and decodes the created object into obj.
+func recreate(ctx context.Context, c dynamic.NamespaceableResourceInterface, desired *unstructured.Unstructured, obj Object, o *Options) (OperationResult, error) {
+	uid := desired.GetUID()
+	policy := metav1.DeletePropagationForeground
+	_, err := Delete(ctx, c, desired, DeleteOptions{
+		PropagationPolicy: &policy,
+		Preconditions:     &metav1.Preconditions{UID: &uid},
+		Wait:              true,
+		DryRun:            o.DryRun,
+	})
+	if err != nil {
+		return OperationResultNone, err
+	}
+	// The object can't be created while the existing one is only deleted in
+	// a dry-run.
+	if o.DryRun != DryRunNone {
+		return OperationResultRecreated, nil
+	}
+
+	created := desired.DeepCopy()
+	clearServerMetadata(created)
+	clearGeneratedJobSelector(created)
+
+	fetchedUns, err := c.Namespace(created.GetNamespace()).Create(ctx, created, metav1.CreateOptions{})
+	if err != nil {
+		return OperationResultNone, err
+	}
+	if err = objectFromUnstructured(fetchedUns, obj); err != nil {
+		return OperationResultRecreated, err
+	}
+	return OperationResultRecreated, nil
+}
+
+// clearServerMetadata removes the metadata fields set by the API server from
+// uns, so it can be sent as a new object.
+func clearServerMetadata(uns *unstructured.Unstructured) {
+	uns.SetUID("")
+	uns.SetResourceVersion("")
+	uns.SetGeneration(0)
+	uns.SetCreationTimestamp(metav1.Time{})
+	uns.SetDeletionTimestamp(nil)
+	uns.SetDeletionGracePeriodSeconds(nil)
+	uns.SetManagedFields(nil)
+	uns.SetSelfLink("")
+}
+
+// clearGeneratedJobSelector removes the selector and pod template labels the
+// API server generates for a Job without a manual selector. They contain the
+// UID of the deleted Job, so the server would reject them for the new one.
+func clearGeneratedJobSelector(uns *unstructured.Unstructured) {
+	if uns.GroupVersionKind().GroupKind() != (schema.GroupKind{Group: "batch", Kind: "Job"}) {
+		return
+	}
+	if manual, _, _ := unstructured.NestedBool(uns.Object, "spec", "manualSelector"); manual {
+		return
+	}
+
+	unstructured.RemoveNestedField(uns.Object, "spec", "selector", "matchLabels", "controller-uid")
+	if matchLabels, _, _ := unstructured.NestedMap(uns.Object, "spec", "selector", "matchLabels"); len(matchLabels) == 0 {
+		unstructured.RemoveNestedField(uns.Object, "spec", "selector", "matchLabels")
+	}
+	if selector, _, _ := unstructured.NestedMap(uns.Object, "spec", "selector"); len(selector) == 0 {
+		unstructured.RemoveNestedField(uns.Object, "spec", "selector")
+	}
+	unstructured.RemoveNestedField(uns.Object, "spec", "template", "metadata", "labels", "controller-uid")
+	unstructured.RemoveNestedField(uns.Object, "spec", "template", "metadata", "labels", "job-name")
+}
@@ -0,0 +1,240 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/dynamic"
+)
+
+var _ = Describe("RecreateOnImmutableFields", func() {
+	var configMapCli dynamic.NamespaceableResourceInterface
+	var immutableCli *immutableWriter
+	var cm *corev1.ConfigMap
+
+	BeforeEach(func() {
+		configMapCli = dynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+		immutableCli = &immutableWriter{NamespaceableResourceInterface: configMapCli}
+
+		cm = &corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("cm-%d", rand.Int31()),
+				Namespace: "default",
+			},
+		}
+		_, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	fetchData := func() map[string]interface{} {
+		fetchedUns, err := configMapCli.Namespace(cm.Namespace).Get(context.TODO(), cm.Name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		data, _, err := unstructured.NestedMap(fetchedUns.Object, "data")
+		Expect(err).NotTo(HaveOccurred())
+		return data
+	}
+
+	It("recreates the object on update", func() {
+		op, err := CreateOrUpdate(context.TODO(), immutableCli, cm, configMapSetter(cm, "foo", "baz"), WithRecreateOnImmutableFields())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultRecreated))
+
+		By("deleting the object with foreground propagation")
+		Expect(immutableCli.propagationPolicy).NotTo(BeNil())
+		Expect(*immutableCli.propagationPolicy).To(Equal(metav1.DeletePropagationForeground))
+
+		By("creating the mutated object")
+		Expect(fetchData()).To(Equal(map[string]interface{}{"foo": "baz"}))
+		Expect(cm.Data).To(Equal(map[string]string{"foo": "baz"}))
+	})
+
+	It("recreates the object on patch", func() {
+		op, err := CreateOrPatch(context.TODO(), immutableCli, cm, configMapSetter(cm, "foo", "baz"), WithRecreateOnImmutableFields())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultRecreated))
+		Expect(fetchData()).To(Equal(map[string]interface{}{"foo": "baz"}))
+	})
+
+	It("keeps the fields unknown to the typed object on patch", func() {
+		immutableCli.NamespaceableResourceInterface = &unknownFieldInjector{NamespaceableResourceInterface: configMapCli}
+
+		op, err := CreateOrPatch(context.TODO(), immutableCli, cm, configMapSetter(cm, "foo", "baz"), WithRecreateOnImmutableFields())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultRecreated))
+
+		By("creating the object with the unknown fields")
+		Expect(immutableCli.created).NotTo(BeNil())
+		Expect(immutableCli.created.Object).To(HaveKeyWithValue("newField", "new"))
+		Expect(immutableCli.created.Object["metadata"]).To(HaveKeyWithValue("newMetadataField", "new"))
+		Expect(immutableCli.created.Object["data"]).To(Equal(map[string]interface{}{"foo": "baz"}))
+	})
+
+	It("returns the error without the option", func() {
+		_, err := CreateOrUpdate(context.TODO(), immutableCli, cm, configMapSetter(cm, "foo", "baz"))
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(fetchData()).To(Equal(map[string]interface{}{"foo": "bar"}))
+	})
+
+	It("recreates jobs with a generated selector", func() {
+		jobCli := &backgroundDeleter{NamespaceableResourceInterface: dynClient.Resource(schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"})}
+		job := &batchv1.Job{
+			TypeMeta: metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("job-%d", rand.Int31()),
+				Namespace: "default",
+			},
+		}
+		image := "busybox:1.32"
+		setTemplate := func() error {
+			job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+			job.Spec.Template.Spec.Containers = []corev1.Container{{Name: "job", Image: image}}
+			return nil
+		}
+
+		op, err := CreateOrUpdate(context.TODO(), jobCli, job, setTemplate)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultCreated))
+		uid := job.UID
+
+		image = "busybox:1.33"
+		op, err = CreateOrUpdate(context.TODO(), jobCli, job, setTemplate, WithRecreateOnImmutableFields())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultRecreated))
+
+		By("generating the selector of the new job")
+		Expect(job.UID).NotTo(Equal(uid))
+		Expect(job.Spec.Selector.MatchLabels).To(Equal(map[string]string{"controller-uid": string(job.UID)}))
+		Expect(job.Spec.Template.Labels).To(HaveKeyWithValue("controller-uid", string(job.UID)))
+		Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal("busybox:1.33"))
+	})
+
+	table.DescribeTable("clears the generated selector of jobs",
+		func(manualSelector bool, expected map[string]interface{}) {
+			job := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"spec": map[string]interface{}{
+					"selector": map[string]interface{}{
+						"matchLabels": map[string]interface{}{"controller-uid": "old-uid"},
+					},
+					"template": map[string]interface{}{
+						"metadata": map[string]interface{}{
+							"labels": map[string]interface{}{"controller-uid": "old-uid", "job-name": "foo", "app": "foo"},
+						},
+					},
+				},
+			}}
+			if manualSelector {
+				Expect(unstructured.SetNestedField(job.Object, true, "spec", "manualSelector")).To(Succeed())
+				expected["manualSelector"] = true
+			}
+
+			clearGeneratedJobSelector(job)
+			Expect(job.Object["spec"]).To(Equal(expected))
+		},
+		table.Entry("generated", false, map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "foo"}},
+			},
+		}),
+		table.Entry("manual", true, map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"controller-uid": "old-uid"},
+			},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"labels": map[string]interface{}{"controller-uid": "old-uid", "job-name": "foo", "app": "foo"},
+				},
+			},
+		}),
+	)
+
+	table.DescribeTable("recognizes immutable field errors",
+		func(err error, expected bool) {
+			Expect(isImmutableFieldError(err)).To(Equal(expected))
+		},
+		table.Entry("immutable field", newImmutableFieldError("foo"), true),
+		table.Entry("other invalid field",
+			apierrors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, "foo", field.ErrorList{field.Required(field.NewPath("data"), "")}), false),
+		table.Entry("conflict", apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "foo", fmt.Errorf("immutable")), false),
+		table.Entry("wrapped", fmt.Errorf("update: %w", newImmutableFieldError("foo")), true),
+	)
+})
+
+func newImmutableFieldError(name string) error {
+	return apierrors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, name, field.ErrorList{
+		field.Invalid(field.NewPath("data"), nil, "field is immutable"),
+	})
+}
+
+// immutableWriter rejects the updates of objects as changing immutable
+// fields, and records the propagation policy of deletions and the created
+// object.
+type immutableWriter struct {
+	dynamic.NamespaceableResourceInterface
+	propagationPolicy *metav1.DeletionPropagation
+	created           *unstructured.Unstructured
+}
+
+func (i *immutableWriter) Namespace(ns string) dynamic.ResourceInterface {
+	return &immutableResourceWriter{ResourceInterface: i.NamespaceableResourceInterface.Namespace(ns), parent: i}
+}
+
+type immutableResourceWriter struct {
+	dynamic.ResourceInterface
+	parent *immutableWriter
+}
+
+func (i *immutableResourceWriter) Update(ctx context.Context, obj *unstructured.Unstructured, options metav1.UpdateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return nil, newImmutableFieldError(obj.GetName())
+}
+
+func (i *immutableResourceWriter) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return nil, newImmutableFieldError(name)
+}
+
+func (i *immutableResourceWriter) Delete(ctx context.Context, name string, options metav1.DeleteOptions, subresources ...string) error {
+	i.parent.propagationPolicy = options.PropagationPolicy
+	// Dependents are only deleted in the foreground by the garbage collector,
+	// which doesn't run in the test environment.
+	policy := metav1.DeletePropagationBackground
+	options.PropagationPolicy = &policy
+	return i.ResourceInterface.Delete(ctx, name, options, subresources...)
+}
+
+func (i *immutableResourceWriter) Create(ctx context.Context, obj *unstructured.Unstructured, options metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	i.parent.created = obj.DeepCopy()
+	return i.ResourceInterface.Create(ctx, obj, options, subresources...)
+}
+
+// backgroundDeleter deletes objects with background propagation, as
+// dependents are only deleted in the foreground by the garbage collector.
+type backgroundDeleter struct {
+	dynamic.NamespaceableResourceInterface
+}
+
+func (b *backgroundDeleter) Namespace(ns string) dynamic.ResourceInterface {
+	return &backgroundResourceDeleter{ResourceInterface: b.NamespaceableResourceInterface.Namespace(ns)}
+}
+
+type backgroundResourceDeleter struct {
+	dynamic.ResourceInterface
+}
+
+func (b *backgroundResourceDeleter) Delete(ctx context.Context, name string, options metav1.DeleteOptions, subresources ...string) error {
+	policy := metav1.DeletePropagationBackground
+	options.PropagationPolicy = &policy
+	return b.ResourceInterface.Delete(ctx, name, options, subresources...)
+}
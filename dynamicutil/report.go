@@ -0,0 +1,152 @@
+package dynamicutil
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"gomodules.xyz/jsonpatch/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+)
+
+const redactedValue = "<redacted>"
+
+// FieldChange is the change of a single field of an object
+type FieldChange struct {
+	// Path is the JSON pointer of the field, e.g. /spec/replicas
+	Path string `json:"path"`
+	// Old is the value before the change, nil when the field is added
+	Old interface{} `json:"old,omitempty"`
+	// New is the value after the change, nil when the field is removed
+	New interface{} `json:"new,omitempty"`
+}
+
+// ChangeReport describes the changes made to an object by a MutateFn
+type ChangeReport struct {
+	// Changes are the changed fields, sorted by path
+	Changes []FieldChange `json:"changes"`
+	// Patch is the RFC 6902 JSON patch from the object before the MutateFn
+	// to the object after it
+	Patch json.RawMessage `json:"patch"`
+}
+
+// CreateOrUpdateWithReport is CreateOrUpdate which also reports the changes
+// made by the MutateFn. Use WithSecretRedaction to keep the values of Secrets
+// out of the report.
+func CreateOrUpdateWithReport(ctx context.Context, c dynamic.NamespaceableResourceInterface, obj Object, f MutateFn, opts ...Option) (OperationResult, *ChangeReport, error) {
+	report := &ChangeReport{}
+	result, err := CreateOrUpdate(ctx, c, obj, f, append(opts[:len(opts):len(opts)], withChangeReport(report))...)
+	return result, report, err
+}
+
+// CreateOrPatchWithReport is CreateOrPatch which also reports the changes
+// made by the MutateFn. Use WithSecretRedaction to keep the values of Secrets
+// out of the report.
+func CreateOrPatchWithReport(ctx context.Context, c dynamic.NamespaceableResourceInterface, obj Object, f MutateFn, opts ...Option) (OperationResult, *ChangeReport, error) {
+	report := &ChangeReport{}
+	result, err := CreateOrPatch(ctx, c, obj, f, append(opts[:len(opts):len(opts)], withChangeReport(report))...)
+	return result, report, err
+}
+
+func withChangeReport(report *ChangeReport) Option {
+	return func(o *Options) {
+		o.report = report
+	}
+}
+
+// reportChanges fills the report of the Options, if any, with the changes
+// from before to after.
+func (o *Options) reportChanges(before, after runtime.Object) error {
+	if o.report == nil {
+		return nil
+	}
+
+	beforeUns, err := unstructuredFromObject(before)
+	if err != nil {
+		return err
+	}
+	afterUns, err := unstructuredFromObject(after)
+	if err != nil {
+		return err
+	}
+	beforeJSON, err := beforeUns.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	afterJSON, err := afterUns.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	ops, err := jsonpatch.CreatePatch(beforeJSON, afterJSON)
+	if err != nil {
+		return err
+	}
+	var beforeDoc interface{}
+	if err = json.Unmarshal(beforeJSON, &beforeDoc); err != nil {
+		return err
+	}
+
+	redact := o.RedactSecrets && isSecret(after)
+	changes := make([]FieldChange, 0, len(ops))
+	for i, op := range ops {
+		change := FieldChange{Path: op.Path, New: op.Value}
+		if op.Operation != "add" {
+			// Operations on arrays are ordered from the last index, so the
+			// old value is still at the same place in the original document.
+			change.Old, _ = valueAtPointer(beforeDoc, op.Path)
+		}
+		if redact {
+			change.Old = redactSecretValue(op.Path, change.Old)
+			change.New = redactSecretValue(op.Path, change.New)
+			ops[i].Value = change.New
+		}
+		changes = append(changes, change)
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		return err
+	}
+
+	o.report.Changes = changes
+	o.report.Patch = patch
+	return nil
+}
+
+func isSecret(obj runtime.Object) bool {
+	if _, ok := obj.(*corev1.Secret); ok {
+		return true
+	}
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	return gvk.Group == corev1.GroupName && gvk.Kind == "Secret"
+}
+
+// redactSecretValue hides the values of data and stringData of a Secret,
+// keeping the keys when a whole map is set.
+func redactSecretValue(path string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	switch {
+	case path == "/data" || path == "/stringData":
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return redactedValue
+		}
+		redacted := make(map[string]interface{}, len(m))
+		for k := range m {
+			redacted[k] = redactedValue
+		}
+		return redacted
+	case strings.HasPrefix(path, "/data/") || strings.HasPrefix(path, "/stringData/"):
+		return redactedValue
+	}
+	return value
+}
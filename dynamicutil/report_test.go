@@ -0,0 +1,129 @@
+package dynamicutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var _ = Describe("ChangeReport", func() {
+	var configMapCli dynamic.NamespaceableResourceInterface
+	var secretCli dynamic.NamespaceableResourceInterface
+	var cm *corev1.ConfigMap
+
+	BeforeEach(func() {
+		configMapCli = dynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+		secretCli = dynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "secrets"})
+
+		cm = &corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("cm-%d", rand.Int31()),
+				Namespace: "default",
+			},
+		}
+	})
+
+	It("reports the fields set on creation", func() {
+		op, report, err := CreateOrUpdateWithReport(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+		Expect(report.Changes).To(ConsistOf(FieldChange{Path: "/data", New: map[string]interface{}{"foo": "bar"}}))
+	})
+
+	It("reports the changed fields and the JSON patch on update", func() {
+		_, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"))
+		Expect(err).NotTo(HaveOccurred())
+
+		op, report, err := CreateOrPatchWithReport(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "baz"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+
+		By("reporting the old and new values")
+		Expect(report.Changes).To(ConsistOf(FieldChange{Path: "/data/foo", Old: "bar", New: "baz"}))
+
+		By("reporting the JSON patch")
+		Expect(report.Patch).To(MatchJSON(`[{"op": "replace", "path": "/data/foo", "value": "baz"}]`))
+	})
+
+	It("reports nothing when unchanged", func() {
+		_, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"))
+		Expect(err).NotTo(HaveOccurred())
+
+		op, report, err := CreateOrUpdateWithReport(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+
+		Expect(report.Changes).To(BeEmpty())
+		Expect(report.Patch).To(MatchJSON(`[]`))
+	})
+
+	It("redacts the values of Secrets", func() {
+		secret := &corev1.Secret{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("secret-%d", rand.Int31()),
+				Namespace: "default",
+			},
+		}
+		setPassword := func(password string) MutateFn {
+			return func() error {
+				secret.StringData = nil
+				secret.Data = map[string][]byte{"password": []byte(password)}
+				return nil
+			}
+		}
+		_, err := CreateOrUpdate(context.TODO(), secretCli, secret, setPassword("hunter1"))
+		Expect(err).NotTo(HaveOccurred())
+
+		op, report, err := CreateOrUpdateWithReport(context.TODO(), secretCli, secret, setPassword("hunter2"), WithSecretRedaction())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+
+		By("reporting the changed key")
+		Expect(report.Changes).To(ConsistOf(FieldChange{Path: "/data/password", Old: redactedValue, New: redactedValue}))
+
+		By("keeping the values out of the patch")
+		patch, err := json.Marshal(report)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(patch)).NotTo(ContainSubstring("aHVudGVy")) // base64 of "hunter"
+	})
+
+	table.DescribeTable("resolves JSON pointers",
+		func(pointer string, expected interface{}, found bool) {
+			doc := map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{"example.com/a~b": "c"},
+				},
+				"items": []interface{}{"x", "y"},
+			}
+			value, ok := valueAtPointer(doc, pointer)
+			Expect(ok).To(Equal(found))
+			if expected == nil {
+				Expect(value).To(BeNil())
+			} else {
+				Expect(value).To(Equal(expected))
+			}
+		},
+		table.Entry("map key", "/metadata/annotations/example.com~1a~0b", "c", true),
+		table.Entry("array index", "/items/1", "y", true),
+		table.Entry("out of range index", "/items/2", nil, false),
+		table.Entry("missing key", "/spec", nil, false),
+	)
+})
+
+func configMapSetter(cm *corev1.ConfigMap, key, value string) MutateFn {
+	return func() error {
+		cm.Data = map[string]string{key: value}
+		return nil
+	}
+}
@@ -0,0 +1,70 @@
+package dynamicutil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// retry runs fn until it succeeds, fails with a non retryable error or the
+// backoff is exhausted, in which case the last error is returned. A backoff
+// without steps makes a single attempt.
+func retry(ctx context.Context, backoff *wait.Backoff, fn func() (OperationResult, error)) (OperationResult, error) {
+	if backoff == nil || backoff.Steps < 1 {
+		return fn()
+	}
+
+	var (
+		result  OperationResult
+		lastErr error
+	)
+	err := wait.ExponentialBackoffWithContext(ctx, *backoff, func() (bool, error) {
+		result, lastErr = fn()
+		switch {
+		case lastErr == nil:
+			return true, nil
+		case isRetryable(lastErr):
+			return false, nil
+		default:
+			return false, lastErr
+		}
+	})
+	if err == wait.ErrWaitTimeout {
+		err = lastErr
+	}
+	return result, err
+}
+
+// isRetryable tells whether the operation failed with err may succeed when
+// retried: conflicts, racing creations, transient server errors, client-side
+// timeouts and connection resets are, validation errors and errors from the
+// MutateFn are not.
+func isRetryable(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if utilnet.IsConnectionReset(err) {
+		return true
+	}
+
+	switch {
+	case apierrors.IsConflict(err), apierrors.IsAlreadyExists(err):
+		return true
+	case apierrors.IsTooManyRequests(err), apierrors.IsServerTimeout(err), apierrors.IsTimeout(err):
+		return true
+	case apierrors.IsInternalError(err), apierrors.IsServiceUnavailable(err), apierrors.IsUnexpectedServerError(err):
+		return true
+	}
+
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		return status.Status().Code >= http.StatusInternalServerError
+	}
+	return false
+}
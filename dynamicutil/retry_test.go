@@ -0,0 +1,178 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+var _ = Describe("Retry", func() {
+	var configMapCli dynamic.NamespaceableResourceInterface
+	var cm *unstructured.Unstructured
+	var backoff wait.Backoff
+	var mutations int
+	var mutator MutateFn
+
+	BeforeEach(func() {
+		configMapCli = dynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+
+		cm = &unstructured.Unstructured{}
+		cm.SetAPIVersion("v1")
+		cm.SetKind("ConfigMap")
+		cm.SetName(fmt.Sprintf("cm-%d", rand.Int31()))
+		cm.SetNamespace("default")
+
+		backoff = wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3}
+		mutations = 0
+		mutator = func() error {
+			mutations++
+			return unstructured.SetNestedField(cm.Object, fmt.Sprint(mutations), "data", "mutations")
+		}
+	})
+
+	It("retries on conflict and re-runs MutateFn", func() {
+		flaky := &flakyWriter{NamespaceableResourceInterface: configMapCli, errs: []error{
+			apierrors.NewAlreadyExists(schema.GroupResource{Resource: "configmaps"}, cm.GetName()),
+		}}
+		op, err := CreateOrUpdate(context.TODO(), flaky, cm, mutator, WithRetry(backoff))
+
+		By("returning no error")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("returning OperationResultCreated")
+		Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+		By("calling MutateFn once per attempt")
+		Expect(mutations).To(Equal(2))
+	})
+
+	It("doesn't retry validation errors", func() {
+		flaky := &flakyWriter{NamespaceableResourceInterface: configMapCli, errs: []error{
+			apierrors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, cm.GetName(), field.ErrorList{}),
+		}}
+		op, err := CreateOrUpdate(context.TODO(), flaky, cm, mutator, WithRetry(backoff))
+
+		By("returning error")
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+
+		By("returning OperationResultNone")
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+
+		By("calling MutateFn once")
+		Expect(mutations).To(Equal(1))
+	})
+
+	It("returns the last error when the backoff is exhausted", func() {
+		flaky := &flakyWriter{NamespaceableResourceInterface: configMapCli}
+		for i := 0; i < backoff.Steps; i++ {
+			flaky.errs = append(flaky.errs, apierrors.NewTooManyRequests("slow down", 0))
+		}
+		op, err := CreateOrPatch(context.TODO(), flaky, cm, mutator, WithRetry(backoff))
+
+		By("returning error")
+		Expect(apierrors.IsTooManyRequests(err)).To(BeTrue())
+
+		By("returning OperationResultNone")
+		Expect(op).To(BeEquivalentTo(OperationResultNone))
+
+		By("calling MutateFn once per attempt")
+		Expect(mutations).To(Equal(backoff.Steps))
+	})
+
+	It("makes a single attempt with a backoff without steps", func() {
+		op, err := CreateOrUpdate(context.TODO(), configMapCli, cm, mutator, WithRetry(wait.Backoff{Duration: time.Second}))
+
+		By("returning no error")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("returning OperationResultCreated")
+		Expect(op).To(BeEquivalentTo(OperationResultCreated))
+
+		By("calling MutateFn once")
+		Expect(mutations).To(Equal(1))
+
+		_, err = configMapCli.Namespace(cm.GetNamespace()).Get(context.TODO(), cm.GetName(), metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	table.DescribeTable("classifies errors",
+		func(err error, retryable bool) {
+			Expect(isRetryable(err)).To(Equal(retryable))
+		},
+		table.Entry("conflict", apierrors.NewConflict(schema.GroupResource{}, "foo", nil), true),
+		table.Entry("already exists", apierrors.NewAlreadyExists(schema.GroupResource{}, "foo"), true),
+		table.Entry("too many requests", apierrors.NewTooManyRequests("", 1), true),
+		table.Entry("server timeout", apierrors.NewServerTimeout(schema.GroupResource{}, "update", 1), true),
+		table.Entry("timeout", apierrors.NewTimeoutError("", 1), true),
+		table.Entry("internal error", apierrors.NewInternalError(fmt.Errorf("boom")), true),
+		table.Entry("service unavailable", apierrors.NewServiceUnavailable(""), true),
+		table.Entry("bad gateway", apierrors.NewGenericServerResponse(502, "update", schema.GroupResource{}, "foo", "", 0, false), true),
+		table.Entry("invalid", apierrors.NewInvalid(schema.GroupKind{}, "foo", field.ErrorList{}), false),
+		table.Entry("bad request", apierrors.NewBadRequest(""), false),
+		table.Entry("forbidden", apierrors.NewForbidden(schema.GroupResource{}, "foo", nil), false),
+		table.Entry("client timeout", &url.Error{Op: "Put", URL: "https://localhost", Err: timeoutError{}}, true),
+		table.Entry("connection reset", &url.Error{Op: "Put", URL: "https://localhost", Err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}, true),
+		table.Entry("mutate error", fmt.Errorf("MutateFn cannot mutate object name and/or object namespace"), false),
+	)
+})
+
+// timeoutError is a client-side timeout like the ones of net/http
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// flakyWriter fails the writes with errs, in order, before delegating to the
+// wrapped client
+type flakyWriter struct {
+	dynamic.NamespaceableResourceInterface
+	errs []error
+}
+
+func (f *flakyWriter) Namespace(ns string) dynamic.ResourceInterface {
+	return &flakyResourceWriter{ResourceInterface: f.NamespaceableResourceInterface.Namespace(ns), parent: f}
+}
+
+func (f *flakyWriter) nextErr() error {
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+type flakyResourceWriter struct {
+	dynamic.ResourceInterface
+	parent *flakyWriter
+}
+
+func (f *flakyResourceWriter) Create(ctx context.Context, obj *unstructured.Unstructured, options metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if err := f.parent.nextErr(); err != nil {
+		return nil, err
+	}
+	return f.ResourceInterface.Create(ctx, obj, options, subresources...)
+}
+
+func (f *flakyResourceWriter) Update(ctx context.Context, obj *unstructured.Unstructured, options metav1.UpdateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if err := f.parent.nextErr(); err != nil {
+		return nil, err
+	}
+	return f.ResourceInterface.Update(ctx, obj, options, subresources...)
+}
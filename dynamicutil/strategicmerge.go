@@ -0,0 +1,91 @@
+package dynamicutil
+
+import (
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// CreateStrategicMergePatch returns the patch from original to modified, and
+// its type. It's a strategic merge patch when the kind of modified is
+// registered in the scheme: the patchMergeKey and patchStrategy tags of the
+// typed struct tell how its lists are merged, e.g. the containers of a pod
+// by name. It's a JSON merge patch otherwise, which replaces whole lists.
+//
+// The API server only accepts strategic merge patches for built-in kinds,
+// custom resources registered in the scheme can only be patched locally.
+func CreateStrategicMergePatch(scheme *runtime.Scheme, original, modified *unstructured.Unstructured) ([]byte, types.PatchType, error) {
+	originalJSON, err := original.MarshalJSON()
+	if err != nil {
+		return nil, "", err
+	}
+	modifiedJSON, err := modified.MarshalJSON()
+	if err != nil {
+		return nil, "", err
+	}
+
+	patchMeta, err := patchMetaForKind(scheme, modified.GroupVersionKind())
+	if err != nil {
+		return nil, "", err
+	}
+	if patchMeta == nil {
+		patch, err := jsonpatch.CreateMergePatch(originalJSON, modifiedJSON)
+		return patch, types.MergePatchType, err
+	}
+	patch, err := strategicpatch.CreateTwoWayMergePatchUsingLookupPatchMeta(originalJSON, modifiedJSON, patchMeta)
+	return patch, types.StrategicMergePatchType, err
+}
+
+// StrategicMergePatch applies the patch to obj and returns the patched
+// object, obj is left unchanged. The patch is applied as a strategic merge
+// patch when the kind of obj is registered in the scheme, as a JSON merge
+// patch otherwise, see CreateStrategicMergePatch.
+func StrategicMergePatch(scheme *runtime.Scheme, obj *unstructured.Unstructured, patch []byte) (*unstructured.Unstructured, error) {
+	objJSON, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	patchMeta, err := patchMetaForKind(scheme, obj.GroupVersionKind())
+	if err != nil {
+		return nil, err
+	}
+	var patched []byte
+	if patchMeta == nil {
+		patched, err = jsonpatch.MergePatch(objJSON, patch)
+	} else {
+		patched, err = strategicpatch.StrategicMergePatchUsingLookupPatchMeta(objJSON, patch, patchMeta)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	patchedUns := &unstructured.Unstructured{}
+	if err = patchedUns.UnmarshalJSON(patched); err != nil {
+		return nil, err
+	}
+	return patchedUns, nil
+}
+
+// patchMetaForKind returns the strategic merge patch metadata of the typed
+// struct of gvk in the scheme, or nil if the kind isn't registered or the
+// scheme is nil.
+func patchMetaForKind(scheme *runtime.Scheme, gvk schema.GroupVersionKind) (strategicpatch.LookupPatchMeta, error) {
+	if scheme == nil {
+		return nil, nil
+	}
+	typed, err := scheme.New(gvk)
+	if err != nil {
+		if runtime.IsNotRegisteredError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if _, ok := typed.(*unstructured.Unstructured); ok {
+		return nil, nil
+	}
+	return strategicpatch.NewPatchMetaFromStruct(typed)
+}
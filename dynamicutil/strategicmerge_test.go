@@ -0,0 +1,84 @@
+package dynamicutil
+
+import (
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("StrategicMergePatch", func() {
+	container := func(name, image string) interface{} {
+		return map[string]interface{}{"name": name, "image": image}
+	}
+	deployment := func(containers ...interface{}) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(deploymentGVK)
+		obj.SetName("foo")
+		Expect(unstructured.SetNestedSlice(obj.Object, containers, "spec", "template", "spec", "containers")).To(Succeed())
+		return obj
+	}
+	containers := func(obj *unstructured.Unstructured) []interface{} {
+		items, _, err := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+		Expect(err).NotTo(HaveOccurred())
+		return items
+	}
+
+	It("merges the lists of the kinds registered in the scheme by key", func() {
+		original := deployment(container("app", "app:v1"))
+		modified := deployment(container("app", "app:v2"), container("proxy", "proxy"))
+
+		patch, patchType, err := CreateStrategicMergePatch(clientgoscheme.Scheme, original, modified)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(patchType).To(Equal(types.StrategicMergePatchType))
+
+		By("keeping the containers added by others")
+		live := deployment(container("app", "app:v1"), container("sidecar", "sidecar"))
+		patched, err := StrategicMergePatch(clientgoscheme.Scheme, live, patch)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(containers(patched)).To(ConsistOf(
+			container("app", "app:v2"),
+			container("sidecar", "sidecar"),
+			container("proxy", "proxy"),
+		))
+		Expect(containers(live)).To(HaveLen(2))
+	})
+
+	It("deletes the list items removed by key", func() {
+		original := deployment(container("app", "app:v1"), container("proxy", "proxy"))
+		modified := deployment(container("app", "app:v1"))
+
+		patch, _, err := CreateStrategicMergePatch(clientgoscheme.Scheme, original, modified)
+		Expect(err).NotTo(HaveOccurred())
+		patched, err := StrategicMergePatch(clientgoscheme.Scheme, original, patch)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(containers(patched)).To(Equal([]interface{}{container("app", "app:v1")}))
+	})
+
+	It("falls back to JSON merge patches for the other kinds", func() {
+		widget := func(sizes ...interface{}) *unstructured.Unstructured {
+			obj := &unstructured.Unstructured{}
+			obj.SetAPIVersion("example.com/v1")
+			obj.SetKind("Widget")
+			obj.SetName("foo")
+			Expect(unstructured.SetNestedSlice(obj.Object, sizes, "spec", "sizes")).To(Succeed())
+			return obj
+		}
+
+		patch, patchType, err := CreateStrategicMergePatch(clientgoscheme.Scheme, widget(int64(1)), widget(int64(1), int64(2)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(patchType).To(Equal(types.MergePatchType))
+		Expect(patch).To(MatchJSON(`{"spec": {"sizes": [1, 2]}}`))
+
+		patched, err := StrategicMergePatch(clientgoscheme.Scheme, widget(int64(3)), patch)
+		Expect(err).NotTo(HaveOccurred())
+		sizes, _, err := unstructured.NestedSlice(patched.Object, "spec", "sizes")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sizes).To(Equal([]interface{}{int64(1), int64(2)}))
+
+		By("replacing whole lists without a scheme")
+		_, patchType, err = CreateStrategicMergePatch(nil, deployment(), deployment(container("app", "app:v1")))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(patchType).To(Equal(types.MergePatchType))
+	})
+})
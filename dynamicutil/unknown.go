@@ -0,0 +1,78 @@
+package dynamicutil
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// replacementObject returns sent, the mutated existing object, as the
+// unstructured object replacing live. When existing is typed, the fields of
+// live unknown to its type are restored.
+func (o *Options) replacementObject(live *unstructured.Unstructured, existing, sent runtime.Object) (*unstructured.Unstructured, error) {
+	objUns, err := stampedUnstructuredFromObject(o.Scheme, sent)
+	if err != nil {
+		return nil, err
+	}
+	if _, isUnstructured := existing.(*unstructured.Unstructured); isUnstructured {
+		return objUns, nil
+	}
+
+	roundTripped, err := unstructuredFromObject(existing)
+	if err != nil {
+		return nil, err
+	}
+	restoreUnknownFields(live.Object, roundTripped.Object, objUns.Object)
+	return objUns, nil
+}
+
+// restoreUnknownFields copies to desired the fields of fetched which are
+// lost by its round-trip through a typed object, like fields added by newer
+// API versions or CRD extensions the compiled types don't know about.
+// roundTripped is fetched after the round-trip.
+//
+// Fields are only restored into maps still present in desired, and into
+// list elements when the list has kept its length, as elements are matched
+// by index.
+func restoreUnknownFields(fetched, roundTripped, desired map[string]interface{}) {
+	for k, value := range fetched {
+		roundTrippedValue, ok := roundTripped[k]
+		if !ok {
+			if _, set := desired[k]; !set {
+				desired[k] = runtime.DeepCopyJSONValue(value)
+			}
+			continue
+		}
+		desiredValue, ok := desired[k]
+		if !ok {
+			continue
+		}
+		restoreUnknownValues(value, roundTrippedValue, desiredValue)
+	}
+}
+
+func restoreUnknownValues(fetched, roundTripped, desired interface{}) {
+	switch f := fetched.(type) {
+	case map[string]interface{}:
+		r, ok := roundTripped.(map[string]interface{})
+		if !ok {
+			return
+		}
+		d, ok := desired.(map[string]interface{})
+		if !ok {
+			return
+		}
+		restoreUnknownFields(f, r, d)
+	case []interface{}:
+		r, ok := roundTripped.([]interface{})
+		if !ok || len(r) != len(f) {
+			return
+		}
+		d, ok := desired.([]interface{})
+		if !ok || len(d) != len(f) {
+			return
+		}
+		for i := range f {
+			restoreUnknownValues(f[i], r[i], d[i])
+		}
+	}
+}
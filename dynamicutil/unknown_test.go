@@ -0,0 +1,115 @@
+package dynamicutil
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var _ = Describe("Unknown fields", func() {
+	var configMapCli dynamic.NamespaceableResourceInterface
+	var cm *corev1.ConfigMap
+
+	BeforeEach(func() {
+		configMapCli = dynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+
+		cm = &corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("cm-%d", rand.Int31()),
+				Namespace: "default",
+			},
+		}
+		_, err := CreateOrUpdate(context.TODO(), configMapCli, cm, configMapSetter(cm, "foo", "bar"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("keeps the fields unknown to the typed object on update", func() {
+		injector := &unknownFieldInjector{NamespaceableResourceInterface: configMapCli}
+
+		op, err := CreateOrUpdate(context.TODO(), injector, cm, configMapSetter(cm, "foo", "baz"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op).To(BeEquivalentTo(OperationResultUpdated))
+
+		By("sending the unknown fields back")
+		Expect(injector.updated).NotTo(BeNil())
+		Expect(injector.updated.Object).To(HaveKeyWithValue("newField", "new"))
+		Expect(injector.updated.Object["metadata"]).To(HaveKeyWithValue("newMetadataField", "new"))
+
+		By("sending the changes of the MutateFn")
+		Expect(injector.updated.Object["data"]).To(Equal(map[string]interface{}{"foo": "baz"}))
+	})
+
+	table.DescribeTable("restores the fields lost by the typed round-trip",
+		func(fetched, roundTripped, desired, expected map[string]interface{}) {
+			restoreUnknownFields(fetched, roundTripped, desired)
+			Expect(desired).To(Equal(expected))
+		},
+		table.Entry("unknown field",
+			map[string]interface{}{"a": "1", "b": "2"},
+			map[string]interface{}{"a": "1"},
+			map[string]interface{}{"a": "3"},
+			map[string]interface{}{"a": "3", "b": "2"}),
+		table.Entry("nested unknown field",
+			map[string]interface{}{"spec": map[string]interface{}{"a": "1", "b": "2"}},
+			map[string]interface{}{"spec": map[string]interface{}{"a": "1"}},
+			map[string]interface{}{"spec": map[string]interface{}{"a": "3"}},
+			map[string]interface{}{"spec": map[string]interface{}{"a": "3", "b": "2"}}),
+		table.Entry("known parent removed by the MutateFn",
+			map[string]interface{}{"spec": map[string]interface{}{"a": "1", "b": "2"}},
+			map[string]interface{}{"spec": map[string]interface{}{"a": "1"}},
+			map[string]interface{}{},
+			map[string]interface{}{}),
+		table.Entry("unknown field of a list element",
+			map[string]interface{}{"items": []interface{}{map[string]interface{}{"a": "1", "b": "2"}}},
+			map[string]interface{}{"items": []interface{}{map[string]interface{}{"a": "1"}}},
+			map[string]interface{}{"items": []interface{}{map[string]interface{}{"a": "3"}}},
+			map[string]interface{}{"items": []interface{}{map[string]interface{}{"a": "3", "b": "2"}}}),
+		table.Entry("list resized by the MutateFn",
+			map[string]interface{}{"items": []interface{}{map[string]interface{}{"a": "1", "b": "2"}}},
+			map[string]interface{}{"items": []interface{}{map[string]interface{}{"a": "1"}}},
+			map[string]interface{}{"items": []interface{}{map[string]interface{}{"a": "1"}, map[string]interface{}{"a": "3"}}},
+			map[string]interface{}{"items": []interface{}{map[string]interface{}{"a": "1"}, map[string]interface{}{"a": "3"}}}),
+	)
+})
+
+// unknownFieldInjector adds fields unknown to the typed objects to the
+// fetched objects, and records the updated object.
+type unknownFieldInjector struct {
+	dynamic.NamespaceableResourceInterface
+	updated *unstructured.Unstructured
+}
+
+func (u *unknownFieldInjector) Namespace(ns string) dynamic.ResourceInterface {
+	return &unknownFieldResourceInjector{ResourceInterface: u.NamespaceableResourceInterface.Namespace(ns), parent: u}
+}
+
+type unknownFieldResourceInjector struct {
+	dynamic.ResourceInterface
+	parent *unknownFieldInjector
+}
+
+func (u *unknownFieldResourceInjector) Get(ctx context.Context, name string, options metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	fetched, err := u.ResourceInterface.Get(ctx, name, options, subresources...)
+	if err != nil {
+		return nil, err
+	}
+	fetched.Object["newField"] = "new"
+	if err = unstructured.SetNestedField(fetched.Object, "new", "metadata", "newMetadataField"); err != nil {
+		return nil, err
+	}
+	return fetched, nil
+}
+
+func (u *unknownFieldResourceInjector) Update(ctx context.Context, obj *unstructured.Unstructured, options metav1.UpdateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	u.parent.updated = obj.DeepCopy()
+	return u.ResourceInterface.Update(ctx, obj, options, subresources...)
+}
@@ -0,0 +1,150 @@
+package dynamicutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	healthNotFound  = Health{Status: HealthInProgress, Message: "object not found"}
+	errHealthFailed = errors.New("health check failed")
+)
+
+// WaitReady blocks until all the objects are current, as assessed by
+// CheckHealth, watching their changes. Objects which don't exist yet are
+// waited for.
+//
+// It fails as soon as one of the objects has failed, or when the context is
+// done, the error tells why the object isn't ready.
+func WaitReady(ctx context.Context, c *Client, objects ...Object) error {
+	clis := make([]dynamic.ResourceInterface, len(objects))
+	for i, obj := range objects {
+		ri, err := c.Resource(obj)
+		if err != nil {
+			return err
+		}
+		clis[i] = ri.Namespace(obj.GetNamespace())
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for i, obj := range objects {
+		wg.Add(1)
+		go func(cli dynamic.ResourceInterface, obj Object) {
+			defer wg.Done()
+			health, err := waitObjectReady(ctx, cli, obj.GetName())
+			if err == nil {
+				return
+			}
+			// The other objects are canceled once one has failed, only the
+			// first error is returned.
+			once.Do(func() {
+				firstErr = fmt.Errorf("%s is not ready: %s: %w", namespacedNameFromObject(obj), health.Message, err)
+				cancel()
+			})
+		}(clis[i], obj)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// waitObjectReady blocks until the object with the given name is current.
+// It returns the last health of the object.
+func waitObjectReady(ctx context.Context, cli dynamic.ResourceInterface, name string) (Health, error) {
+	health := healthNotFound
+	for {
+		var resourceVersion string
+		fetchedUns, err := cli.Get(ctx, name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			health = healthNotFound
+		case err != nil:
+			return health, err
+		default:
+			ready, err := checkReady(fetchedUns, &health)
+			if err != nil || ready {
+				return health, err
+			}
+			resourceVersion = fetchedUns.GetResourceVersion()
+		}
+
+		w, err := cli.Watch(ctx, metav1.ListOptions{
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", name).String(),
+			ResourceVersion: resourceVersion,
+		})
+		if err != nil {
+			return health, err
+		}
+		ready, err := watchForReady(ctx, w, name, &health)
+		w.Stop()
+		if err != nil || ready {
+			return health, err
+		}
+	}
+}
+
+// watchForReady tells whether the object with the given name becomes
+// current. It returns false when the watch is closed, or has expired, and
+// must be started again.
+func watchForReady(ctx context.Context, w watch.Interface, name string, health *Health) (bool, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return false, nil
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				obj, ok := event.Object.(*unstructured.Unstructured)
+				if !ok || obj.GetName() != name {
+					continue
+				}
+				if ready, err := checkReady(obj, health); err != nil || ready {
+					return ready, err
+				}
+			case watch.Deleted:
+				*health = healthNotFound
+			case watch.Error:
+				err := apierrors.FromObject(event.Object)
+				if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+					return false, nil
+				}
+				return false, err
+			}
+		}
+	}
+}
+
+// checkReady updates health with the health of obj, and tells whether it's
+// current. An error is returned if it has failed.
+func checkReady(obj *unstructured.Unstructured, health *Health) (bool, error) {
+	h, err := CheckHealth(obj)
+	if err != nil {
+		return false, err
+	}
+	*health = h
+	switch h.Status {
+	case HealthCurrent:
+		return true, nil
+	case HealthFailed:
+		return false, errHealthFailed
+	}
+	return false, nil
+}
@@ -0,0 +1,266 @@
+package dynamicutil
+
+import (
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var workloadScheme = newWorkloadScheme()
+
+func newWorkloadScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		panic(err)
+	}
+	AddWorkloadDefaultingFuncs(scheme)
+	return scheme
+}
+
+// DefaultWorkloads is a DefaultingFunc setting the defaults the API server
+// sets on Pods, Deployments, StatefulSets, DaemonSets, ReplicaSets, Jobs and
+// CronJobs, like the defaulters of k8s.io/kubernetes do for the common
+// fields. Objects of other kinds are left unchanged.
+//
+//	CreateOrUpdate(ctx, c, deploy, mutate, WithDefaultingFunc(DefaultWorkloads))
+func DefaultWorkloads(obj runtime.Object) {
+	schemeDefaulter(workloadScheme)(obj)
+}
+
+// AddWorkloadDefaultingFuncs registers the defaulting functions of
+// DefaultWorkloads in the scheme, for WithDefaulting.
+func AddWorkloadDefaultingFuncs(scheme *runtime.Scheme) {
+	scheme.AddTypeDefaultingFunc(&corev1.Pod{}, func(obj interface{}) {
+		pod := obj.(*corev1.Pod)
+		if pod.Spec.EnableServiceLinks == nil {
+			enableServiceLinks := corev1.DefaultEnableServiceLinks
+			pod.Spec.EnableServiceLinks = &enableServiceLinks
+		}
+		defaultPodSpec(&pod.Spec)
+	})
+	scheme.AddTypeDefaultingFunc(&appsv1.Deployment{}, func(obj interface{}) {
+		defaultDeploymentSpec(&obj.(*appsv1.Deployment).Spec)
+	})
+	scheme.AddTypeDefaultingFunc(&appsv1.StatefulSet{}, func(obj interface{}) {
+		defaultStatefulSetSpec(&obj.(*appsv1.StatefulSet).Spec)
+	})
+	scheme.AddTypeDefaultingFunc(&appsv1.DaemonSet{}, func(obj interface{}) {
+		defaultDaemonSetSpec(&obj.(*appsv1.DaemonSet).Spec)
+	})
+	scheme.AddTypeDefaultingFunc(&appsv1.ReplicaSet{}, func(obj interface{}) {
+		spec := &obj.(*appsv1.ReplicaSet).Spec
+		defaultInt32(&spec.Replicas, 1)
+		defaultPodSpec(&spec.Template.Spec)
+	})
+	scheme.AddTypeDefaultingFunc(&batchv1.Job{}, func(obj interface{}) {
+		defaultJobSpec(&obj.(*batchv1.Job).Spec)
+	})
+	scheme.AddTypeDefaultingFunc(&batchv1beta1.CronJob{}, func(obj interface{}) {
+		defaultCronJobSpec(&obj.(*batchv1beta1.CronJob).Spec)
+	})
+}
+
+func defaultDeploymentSpec(spec *appsv1.DeploymentSpec) {
+	defaultInt32(&spec.Replicas, 1)
+	if spec.Strategy.Type == "" {
+		spec.Strategy.Type = appsv1.RollingUpdateDeploymentStrategyType
+	}
+	if spec.Strategy.Type == appsv1.RollingUpdateDeploymentStrategyType {
+		if spec.Strategy.RollingUpdate == nil {
+			spec.Strategy.RollingUpdate = &appsv1.RollingUpdateDeployment{}
+		}
+		defaultIntOrString(&spec.Strategy.RollingUpdate.MaxUnavailable, intstr.FromString("25%"))
+		defaultIntOrString(&spec.Strategy.RollingUpdate.MaxSurge, intstr.FromString("25%"))
+	}
+	defaultInt32(&spec.RevisionHistoryLimit, 10)
+	defaultInt32(&spec.ProgressDeadlineSeconds, 600)
+	defaultPodSpec(&spec.Template.Spec)
+}
+
+func defaultStatefulSetSpec(spec *appsv1.StatefulSetSpec) {
+	if spec.PodManagementPolicy == "" {
+		spec.PodManagementPolicy = appsv1.OrderedReadyPodManagement
+	}
+	if spec.UpdateStrategy.Type == "" {
+		spec.UpdateStrategy.Type = appsv1.RollingUpdateStatefulSetStrategyType
+	}
+	if spec.UpdateStrategy.Type == appsv1.RollingUpdateStatefulSetStrategyType {
+		if spec.UpdateStrategy.RollingUpdate == nil {
+			spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateStatefulSetStrategy{}
+		}
+		defaultInt32(&spec.UpdateStrategy.RollingUpdate.Partition, 0)
+	}
+	defaultInt32(&spec.Replicas, 1)
+	defaultInt32(&spec.RevisionHistoryLimit, 10)
+	defaultPodSpec(&spec.Template.Spec)
+}
+
+func defaultDaemonSetSpec(spec *appsv1.DaemonSetSpec) {
+	if spec.UpdateStrategy.Type == "" {
+		spec.UpdateStrategy.Type = appsv1.RollingUpdateDaemonSetStrategyType
+	}
+	if spec.UpdateStrategy.Type == appsv1.RollingUpdateDaemonSetStrategyType {
+		if spec.UpdateStrategy.RollingUpdate == nil {
+			spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateDaemonSet{}
+		}
+		defaultIntOrString(&spec.UpdateStrategy.RollingUpdate.MaxUnavailable, intstr.FromInt(1))
+	}
+	defaultInt32(&spec.RevisionHistoryLimit, 10)
+	defaultPodSpec(&spec.Template.Spec)
+}
+
+func defaultJobSpec(spec *batchv1.JobSpec) {
+	if spec.Completions == nil && spec.Parallelism == nil {
+		defaultInt32(&spec.Completions, 1)
+	}
+	defaultInt32(&spec.Parallelism, 1)
+	defaultInt32(&spec.BackoffLimit, 6)
+	defaultPodSpec(&spec.Template.Spec)
+}
+
+func defaultCronJobSpec(spec *batchv1beta1.CronJobSpec) {
+	if spec.ConcurrencyPolicy == "" {
+		spec.ConcurrencyPolicy = batchv1beta1.AllowConcurrent
+	}
+	if spec.Suspend == nil {
+		suspend := false
+		spec.Suspend = &suspend
+	}
+	defaultInt32(&spec.SuccessfulJobsHistoryLimit, 3)
+	defaultInt32(&spec.FailedJobsHistoryLimit, 1)
+	defaultPodSpec(&spec.JobTemplate.Spec.Template.Spec)
+}
+
+func defaultPodSpec(spec *corev1.PodSpec) {
+	if spec.DNSPolicy == "" {
+		spec.DNSPolicy = corev1.DNSClusterFirst
+	}
+	if spec.RestartPolicy == "" {
+		spec.RestartPolicy = corev1.RestartPolicyAlways
+	}
+	if spec.SecurityContext == nil {
+		spec.SecurityContext = &corev1.PodSecurityContext{}
+	}
+	if spec.TerminationGracePeriodSeconds == nil {
+		terminationGracePeriodSeconds := int64(corev1.DefaultTerminationGracePeriodSeconds)
+		spec.TerminationGracePeriodSeconds = &terminationGracePeriodSeconds
+	}
+	if spec.SchedulerName == "" {
+		spec.SchedulerName = corev1.DefaultSchedulerName
+	}
+	for i := range spec.InitContainers {
+		defaultContainer(&spec.InitContainers[i], spec.HostNetwork)
+	}
+	for i := range spec.Containers {
+		defaultContainer(&spec.Containers[i], spec.HostNetwork)
+	}
+	for i := range spec.Volumes {
+		defaultVolume(&spec.Volumes[i])
+	}
+}
+
+func defaultContainer(c *corev1.Container, hostNetwork bool) {
+	if c.ImagePullPolicy == "" {
+		c.ImagePullPolicy = defaultPullPolicy(c.Image)
+	}
+	if c.TerminationMessagePath == "" {
+		c.TerminationMessagePath = corev1.TerminationMessagePathDefault
+	}
+	if c.TerminationMessagePolicy == "" {
+		c.TerminationMessagePolicy = corev1.TerminationMessageReadFile
+	}
+	for i := range c.Ports {
+		port := &c.Ports[i]
+		if port.Protocol == "" {
+			port.Protocol = corev1.ProtocolTCP
+		}
+		if hostNetwork && port.HostPort == 0 {
+			port.HostPort = port.ContainerPort
+		}
+	}
+	for i := range c.Env {
+		if from := c.Env[i].ValueFrom; from != nil && from.FieldRef != nil && from.FieldRef.APIVersion == "" {
+			from.FieldRef.APIVersion = "v1"
+		}
+	}
+	for _, probe := range []*corev1.Probe{c.LivenessProbe, c.ReadinessProbe, c.StartupProbe} {
+		if probe != nil {
+			defaultProbe(probe)
+		}
+	}
+}
+
+// defaultPullPolicy returns Always for images without a tag or with the
+// latest tag, IfNotPresent otherwise.
+func defaultPullPolicy(image string) corev1.PullPolicy {
+	if strings.Contains(image, "@") {
+		return corev1.PullIfNotPresent
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	sep := strings.LastIndex(name, ":")
+	if sep < 0 || name[sep+1:] == "latest" {
+		return corev1.PullAlways
+	}
+	return corev1.PullIfNotPresent
+}
+
+func defaultProbe(probe *corev1.Probe) {
+	if probe.TimeoutSeconds == 0 {
+		probe.TimeoutSeconds = 1
+	}
+	if probe.PeriodSeconds == 0 {
+		probe.PeriodSeconds = 10
+	}
+	if probe.SuccessThreshold == 0 {
+		probe.SuccessThreshold = 1
+	}
+	if probe.FailureThreshold == 0 {
+		probe.FailureThreshold = 3
+	}
+	if get := probe.HTTPGet; get != nil {
+		if get.Path == "" {
+			get.Path = "/"
+		}
+		if get.Scheme == "" {
+			get.Scheme = corev1.URISchemeHTTP
+		}
+	}
+}
+
+func defaultVolume(volume *corev1.Volume) {
+	source := &volume.VolumeSource
+	if (*source == corev1.VolumeSource{}) {
+		source.EmptyDir = &corev1.EmptyDirVolumeSource{}
+	}
+	if source.Secret != nil {
+		defaultInt32(&source.Secret.DefaultMode, corev1.SecretVolumeSourceDefaultMode)
+	}
+	if source.ConfigMap != nil {
+		defaultInt32(&source.ConfigMap.DefaultMode, corev1.ConfigMapVolumeSourceDefaultMode)
+	}
+	if source.Projected != nil {
+		defaultInt32(&source.Projected.DefaultMode, corev1.ProjectedVolumeSourceDefaultMode)
+	}
+	if source.HostPath != nil && source.HostPath.Type == nil {
+		hostPathType := corev1.HostPathUnset
+		source.HostPath.Type = &hostPathType
+	}
+}
+
+func defaultInt32(field **int32, value int32) {
+	if *field == nil {
+		*field = &value
+	}
+}
+
+func defaultIntOrString(field **intstr.IntOrString, value intstr.IntOrString) {
+	if *field == nil {
+		*field = &value
+	}
+}
@@ -3,13 +3,16 @@ module github.com/timonwong/k8sutils
 go 1.15
 
 require (
+	github.com/evanphx/json-patch v4.9.0+incompatible
 	github.com/onsi/ginkgo v1.16.4
 	github.com/onsi/gomega v1.14.0
 	github.com/stretchr/testify v1.6.1
 	golang.org/x/net v0.0.0-20210726213435-c6fcb2dbf985 // indirect
 	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c // indirect
+	gomodules.xyz/jsonpatch/v2 v2.1.0
 	k8s.io/api v0.20.8
 	k8s.io/apimachinery v0.20.8
 	k8s.io/client-go v0.20.8
 	sigs.k8s.io/controller-runtime v0.8.3
+	sigs.k8s.io/yaml v1.2.0
 )